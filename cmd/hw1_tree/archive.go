@@ -0,0 +1,304 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"go-webservices/cmd/hw1_tree/tree"
+)
+
+// tarFS lists a tar archive from its headers alone. Tar has no index, so the
+// contents of a file are read by scanning the archive again when it is opened,
+// or of all files in a single pass with scanFiles.
+type tarFS struct {
+	archive string
+	gzipped bool
+	files   map[string]*tarFile
+	lastID  uint64
+}
+
+func (fsys *tarFS) newID() uint64 {
+	fsys.lastID++
+	return fsys.lastID
+}
+
+type tarFile struct {
+	name    string
+	mode    fs.FileMode
+	modTime time.Time
+	size    int64
+	// index is the position of the header in the archive, -1 for
+	// directories that have none
+	index int
+	// id tells entries apart for tree.Identified
+	id       uint64
+	target   string
+	children []fs.DirEntry
+	hdr      *tar.Header
+}
+
+func (f *tarFile) Name() string       { return f.name }
+func (f *tarFile) Size() int64        { return f.size }
+func (f *tarFile) Mode() fs.FileMode  { return f.mode }
+func (f *tarFile) ModTime() time.Time { return f.modTime }
+func (f *tarFile) IsDir() bool        { return f.mode.IsDir() }
+func (f *tarFile) FileID() uint64     { return f.id }
+
+// Sys returns the header of the entry, or nil for the root and directories
+// only implied by the paths of other entries. A typed nil would pass the
+// type assertions of callers.
+func (f *tarFile) Sys() interface{} {
+	if f.hdr == nil {
+		return nil
+	}
+	return f.hdr
+}
+
+// tarReader reads an archive from the start, closing the file with it.
+type tarReader struct {
+	*tar.Reader
+	io.Closer
+}
+
+func (fsys *tarFS) open() (*tarReader, error) {
+	file, err := os.Open(fsys.archive)
+	if err != nil {
+		return nil, err
+	}
+	var r io.Reader = file
+	if fsys.gzipped {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		r = gz
+	}
+	return &tarReader{Reader: tar.NewReader(r), Closer: file}, nil
+}
+
+// entryName is the path an entry is listed under, "" for entries that
+// cannot be listed.
+func entryName(hdr *tar.Header) string {
+	name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+	if name == "." || !fs.ValidPath(name) {
+		return ""
+	}
+	return name
+}
+
+// scan calls fn for every entry of the archive with its position, reading
+// from the entry until fn returns.
+func (fsys *tarFS) scan(fn func(index int, hdr *tar.Header, r io.Reader) error) error {
+	tr, err := fsys.open()
+	if err != nil {
+		return err
+	}
+	defer tr.Close()
+	for index := 0; ; index++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(index, hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func readTar(archive string, gzipped bool) (*tarFS, error) {
+	fsys := &tarFS{
+		archive: archive,
+		gzipped: gzipped,
+		files:   map[string]*tarFile{".": {name: ".", mode: fs.ModeDir | 0755, index: -1}},
+	}
+	err := fsys.scan(func(index int, hdr *tar.Header, r io.Reader) error {
+		name := entryName(hdr)
+		if name == "" {
+			return nil
+		}
+		f := &tarFile{name: path.Base(name), mode: hdr.FileInfo().Mode(), modTime: hdr.ModTime, index: index, id: fsys.newID(), hdr: hdr}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+		case tar.TypeReg, tar.TypeRegA:
+			f.size = hdr.Size
+		case tar.TypeSymlink:
+			f.size = int64(len(hdr.Linkname))
+			f.target = hdr.Linkname
+		default:
+			return nil
+		}
+		// a later entry for the same name replaces the earlier one, as it
+		// would on extraction
+		fsys.files[name] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	fsys.link()
+	return fsys, nil
+}
+
+// link fills in the children of every directory, creating the ones that only
+// appear as a prefix of other entries. Symlinks are listed as a *tree.Link,
+// like the ones on disk.
+func (fsys *tarFS) link() {
+	names := make([]string, 0, len(fsys.files))
+	for name := range fsys.files {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		for dir := path.Dir(name); fsys.files[dir] == nil; dir = path.Dir(dir) {
+			fsys.files[dir] = &tarFile{name: path.Base(dir), mode: fs.ModeDir | 0755, index: -1, id: fsys.newID()}
+		}
+	}
+	for name, f := range fsys.files {
+		if name == "." {
+			continue
+		}
+		var info fs.FileInfo = f
+		if f.mode&fs.ModeSymlink != 0 {
+			link := &tree.Link{FileInfo: f, Target: f.target}
+			if resolved := fsys.lookup(name, true); resolved != nil {
+				link.Resolved = resolved
+			}
+			info = link
+		}
+		parent := fsys.files[path.Dir(name)]
+		parent.children = append(parent.children, fs.FileInfoToDirEntry(info))
+	}
+	for _, f := range fsys.files {
+		sort.Slice(f.children, func(i, j int) bool {
+			return f.children[i].Name() < f.children[j].Name()
+		})
+	}
+}
+
+// scanFiles calls fn with the contents of every regular file, in archive
+// order, reading the archive once.
+func (fsys *tarFS) scanFiles(fn func(name string, r io.Reader) error) error {
+	return fsys.scan(func(index int, hdr *tar.Header, r io.Reader) error {
+		name := entryName(hdr)
+		f := fsys.files[name]
+		if name == "" || f == nil || f.index != index || !f.mode.IsRegular() {
+			return nil
+		}
+		return fn(name, r)
+	})
+}
+
+func (fsys *tarFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	f := fsys.lookup(name, true)
+	if f == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &openTarFile{fsys: fsys, file: f}, nil
+}
+
+// maxLinkHops bounds the links followed for one name, like ELOOP on disk.
+const maxLinkHops = 40
+
+// lookup finds the entry at name, following the links on the way and, with
+// follow set, a link at the end. Links leading outside the archive, absolute
+// ones included, and chains of more than maxLinkHops links lead nowhere.
+func (fsys *tarFS) lookup(name string, follow bool) *tarFile {
+	parts := splitPath(name)
+	dir := "."
+	for hops := 0; len(parts) > 0; {
+		next := path.Join(dir, parts[0])
+		f := fsys.files[next]
+		if f == nil {
+			return nil
+		}
+		if f.mode&fs.ModeSymlink == 0 || len(parts) == 1 && !follow {
+			dir, parts = next, parts[1:]
+			continue
+		}
+		if hops++; hops > maxLinkHops || path.IsAbs(f.target) {
+			return nil
+		}
+		target := path.Join(dir, f.target)
+		if target == ".." || strings.HasPrefix(target, "../") {
+			return nil
+		}
+		dir, parts = ".", append(splitPath(target), parts[1:]...)
+	}
+	return fsys.files[dir]
+}
+
+func splitPath(name string) []string {
+	if name == "." {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
+
+// openTarFile finds the contents of a regular file on the first Read.
+type openTarFile struct {
+	fsys   *tarFS
+	file   *tarFile
+	r      *tarReader
+	offset int
+}
+
+func (f *openTarFile) Stat() (fs.FileInfo, error) {
+	return f.file, nil
+}
+
+func (f *openTarFile) Read(p []byte) (int, error) {
+	if !f.file.mode.IsRegular() {
+		return 0, &fs.PathError{Op: "read", Path: f.file.name, Err: fs.ErrInvalid}
+	}
+	if f.r == nil {
+		tr, err := f.fsys.open()
+		if err != nil {
+			return 0, err
+		}
+		f.r = tr
+		for index := 0; index <= f.file.index; index++ {
+			if _, err := tr.Next(); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return 0, err
+			}
+		}
+	}
+	return f.r.Read(p)
+}
+
+func (f *openTarFile) Close() error {
+	if f.r == nil {
+		return nil
+	}
+	return f.r.Close()
+}
+
+func (f *openTarFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.file.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.file.name, Err: fs.ErrInvalid}
+	}
+	rest := f.file.children[f.offset:]
+	if n > 0 && len(rest) > n {
+		rest = rest[:n]
+	}
+	f.offset += len(rest)
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	return rest, nil
+}
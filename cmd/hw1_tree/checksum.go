@@ -0,0 +1,233 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	checksumSHA256 = "sha256"
+	checksumMD5    = "md5"
+	checksumCRC32  = "crc32"
+)
+
+func newHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case checksumSHA256:
+		return sha256.New(), nil
+	case checksumMD5:
+		return md5.New(), nil
+	case checksumCRC32:
+		return crc32.NewIEEE(), nil
+	}
+	return nil, fmt.Errorf("unknown checksum %q", algo)
+}
+
+func hashFile(fsys fs.FS, name, algo string) (string, error) {
+	h, err := newHash(algo)
+	if err != nil {
+		return "", err
+	}
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+type hashJob struct {
+	file *node
+	name string
+}
+
+// collectFiles lists the regular files below dir that want accepts. Links
+// are skipped: their bytes belong to the target, which is listed elsewhere
+// or lies outside the tree.
+func collectFiles(dir *node, name string, want func(*node) bool, jobs []hashJob) []hashJob {
+	for _, child := range dir.children {
+		childName := path.Join(name, child.info.Name())
+		switch {
+		case child.info.IsDir():
+			jobs = collectFiles(child, childName, want, jobs)
+		case child.info.Mode().IsRegular() && want(child):
+			jobs = append(jobs, hashJob{file: child, name: childName})
+		}
+	}
+	return jobs
+}
+
+// hashTree sets the checksum of the files accepted by want using a pool of
+// opts.workers goroutines, one per CPU if unset. The first error is returned
+// once all workers are done.
+func hashTree(src source, root *node, opts options, want func(*node) bool) error {
+	jobs := collectFiles(root, ".", want, nil)
+	if scanner, ok := src.fsys.(fileScanner); ok {
+		var err error
+		if jobs, err = scanHashes(scanner, jobs, opts.checksum); err != nil {
+			return src.pathError(err)
+		}
+	}
+	workers := opts.workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	queue := make(chan hashJob)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				sum, err := hashFile(src.fsys, job.name, opts.checksum)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = src.pathError(err)
+					}
+					mu.Unlock()
+					continue
+				}
+				job.file.sum = sum
+			}
+		}()
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+	return firstErr
+}
+
+// fileScanner is implemented by sources that read all their files far faster
+// in one pass than one by one, like tar archives.
+type fileScanner interface {
+	scanFiles(fn func(name string, r io.Reader) error) error
+}
+
+// scanHashes hashes the files of jobs in a single pass over the source and
+// returns the jobs left, the files only reachable through followed links.
+func scanHashes(scanner fileScanner, jobs []hashJob, algo string) ([]hashJob, error) {
+	byName := make(map[string][]hashJob, len(jobs))
+	for _, job := range jobs {
+		byName[job.name] = append(byName[job.name], job)
+	}
+	err := scanner.scanFiles(func(name string, r io.Reader) error {
+		found, ok := byName[name]
+		if !ok {
+			return nil
+		}
+		h, err := newHash(algo)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(h, r); err != nil {
+			return &fs.PathError{Op: "read", Path: name, Err: err}
+		}
+		for _, job := range found {
+			job.file.sum = hex.EncodeToString(h.Sum(nil))
+		}
+		delete(byName, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rest []hashJob
+	for _, job := range jobs {
+		if _, ok := byName[job.name]; ok {
+			rest = append(rest, job)
+		}
+	}
+	return rest, nil
+}
+
+func allFiles(*node) bool {
+	return true
+}
+
+type dupeGroup struct {
+	size  int64
+	names []string
+}
+
+func (g dupeGroup) wasted() int64 {
+	return g.size * int64(len(g.names)-1)
+}
+
+// findDupes groups files with the same content. Only files sharing their size
+// with another one are hashed, and empty files are left out since they waste
+// nothing.
+func findDupes(src source, root *node, opts options) ([]dupeGroup, error) {
+	sizes := map[int64]int{}
+	for _, job := range collectFiles(root, ".", allFiles, nil) {
+		sizes[job.file.info.Size()]++
+	}
+	candidate := func(n *node) bool {
+		return n.info.Size() > 0 && sizes[n.info.Size()] > 1
+	}
+	if err := hashTree(src, root, opts, candidate); err != nil {
+		return nil, err
+	}
+
+	bySum := map[string]*dupeGroup{}
+	for _, job := range collectFiles(root, ".", candidate, nil) {
+		key := fmt.Sprintf("%d:%s", job.file.info.Size(), job.file.sum)
+		g, ok := bySum[key]
+		if !ok {
+			g = &dupeGroup{size: job.file.info.Size()}
+			bySum[key] = g
+		}
+		g.names = append(g.names, job.name)
+	}
+
+	var groups []dupeGroup
+	for _, g := range bySum {
+		if len(g.names) > 1 {
+			sort.Strings(g.names)
+			groups = append(groups, *g)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].wasted() != groups[j].wasted() {
+			return groups[i].wasted() > groups[j].wasted()
+		}
+		return groups[i].names[0] < groups[j].names[0]
+	})
+	return groups, nil
+}
+
+func printDupes(out io.Writer, groups []dupeGroup, opts options) error {
+	var wasted int64
+	for _, g := range groups {
+		wasted += g.wasted()
+		_, err := fmt.Fprintf(out, "%d copies of %s (%s wasted):\n\t%s\n\n", len(g.names),
+			formatSize(g.size, opts.human), formatSize(g.wasted(), opts.human), strings.Join(g.names, "\n\t"))
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(out, "%s, %s wasted\n", plural(len(groups), "group", "groups"), formatSize(wasted, opts.human))
+	return err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTreeChecksum(t *testing.T) {
+	cases := []struct {
+		checksum string
+		expected string
+	}{
+		{checksumCRC32, `├───[ee82b7a9] file.txt (19b)
+└───[26524903] gopher.png (70372b)
+`},
+		{checksumMD5, `├───[122a10d6a32262217e0e79e504f2e447] file.txt (19b)
+└───[ca1f746d6f232f87fca4e4d94ef6f3ab] gopher.png (70372b)
+`},
+	}
+	for _, c := range cases {
+		for _, workers := range []int{0, 1, 3} {
+			out := new(bytes.Buffer)
+			opts := options{printFiles: true, checksum: c.checksum, workers: workers}
+			if err := renderTree(out, "testdata/project", opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out.String() != c.expected {
+				t.Errorf("%s results not match\nGot:\n%v\nExpected:\n%v", c.checksum, out.String(), c.expected)
+			}
+		}
+	}
+}
+
+const testDupesResult = `7 copies of 68.7KiB (412.3KiB wasted):
+	project/gopher.png
+	static/a_lorem/gopher.png
+	static/a_lorem/ipsum/gopher.png
+	static/z_lorem/gopher.png
+	static/z_lorem/ipsum/gopher.png
+	zline/lorem/gopher.png
+	zline/lorem/ipsum/gopher.png
+
+1 group, 412.3KiB wasted
+`
+
+func TestTreeDupes(t *testing.T) {
+	out := new(bytes.Buffer)
+	opts := options{printFiles: true, dupes: true, human: true, workers: 4}
+	if err := renderTree(out, "testdata", opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != testDupesResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out.String(), testDupesResult)
+	}
+}
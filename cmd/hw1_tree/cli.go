@@ -0,0 +1,349 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"go-webservices/cmd/hw1_tree/tree"
+)
+
+// version is replaced at build time with -ldflags "-X main.version=...".
+var version = "devel"
+
+const (
+	exitOK         = 0
+	exitDiffer     = 1
+	exitUsage      = 2
+	exitIncomplete = 3
+	exitFailure    = 4
+)
+
+const usageHeader = `usage: tree [flags] <path>...
+       tree [flags] snapshot <dir> <file>
+       tree [flags] diff <snapshot> <dir or snapshot>
+       tree [flags] serve <addr> <dir>
+
+Flags may appear anywhere on the command line, "--" ends them.
+
+flags:
+`
+
+const usageFooter = `
+exit status:
+  0  success
+  1  diff found differences
+  2  invalid arguments
+  3  the tree was printed, but some directories could not be read
+  4  any other error
+`
+
+// command is a parsed command line. The flags that only set a default for
+// another one are kept apart and applied once all flags are known.
+type command struct {
+	args   []string
+	opts   options
+	output string
+
+	help, version      bool
+	hash, dates        bool
+	byVersion, byMtime bool
+}
+
+func newFlagSet(cmd *command) *flag.FlagSet {
+	opts := &cmd.opts
+	fs := flag.NewFlagSet("tree", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	fs.BoolVar(&opts.printFiles, "f", false, "print files as well as directories")
+	fs.StringVar(&opts.format, "format", formatText, "output `format`: text, json, yaml, html, markdown or markdown-fenced")
+	fs.StringVar(&opts.charset, "charset", charsetUTF8, "draw the tree with utf-8 or ascii `characters`")
+	fs.StringVar(&cmd.output, "o", "", "write the output to `file` instead of stdout")
+	fs.StringVar(&opts.baseURL, "base-url", "", "link files below `url` in html and markdown output")
+	fs.Func("L", "descend only `depth` levels deep", func(v string) error { return setOption(opts, "-L", v) })
+	fs.Func("I", "exclude entries matching `glob`, may be repeated", func(v string) error { return setOption(opts, "-I", v) })
+	fs.Func("P", "list only files matching `glob`, may be repeated", func(v string) error { return setOption(opts, "-P", v) })
+	fs.BoolVar(&opts.gitignore, "gitignore", false, "skip entries ignored by .gitignore files")
+	fs.Func("j", "read directories with `n` workers", func(v string) error { return setOption(opts, "-j", v) })
+	fs.BoolVar(&opts.du, "du", false, "print the total size of every directory")
+	fs.BoolVar(&opts.human, "h", false, "print sizes in human readable units")
+	fs.BoolVar(&opts.report, "report", false, "print the number of directories and files at the end")
+	fs.BoolVar(&opts.follow, "l", false, "follow links to directories")
+	fs.BoolVar(&opts.strict, "strict", false, "fail on the first unreadable directory")
+	fs.StringVar(&opts.sortBy, "sort", "", "sort by `key`: name, version, size or mtime")
+	fs.BoolVar(&cmd.byVersion, "v", false, "sort by version, same as --sort version")
+	fs.BoolVar(&cmd.byMtime, "t", false, "sort by modification time, same as --sort mtime")
+	fs.BoolVar(&opts.dirsFirst, "dirsfirst", false, "list directories before files")
+	fs.BoolVar(&opts.reverse, "r", false, "reverse the sort order")
+	fs.BoolVar(&cmd.hash, "hash", false, "hash files with sha256, same as --checksum sha256")
+	fs.Func("checksum", "hash files with `algorithm`: sha256, md5 or crc32", func(v string) error { return setOption(opts, "--checksum", v) })
+	fs.BoolVar(&opts.dupes, "dupes", false, "report files with identical content")
+	fs.BoolVar(&opts.perms, "p", false, "print permissions")
+	fs.BoolVar(&opts.owner, "u", false, "print the owner")
+	fs.BoolVar(&opts.group, "g", false, "print the group")
+	fs.BoolVar(&cmd.dates, "D", false, "print the modification time")
+	fs.StringVar(&opts.timeFormat, "timefmt", "", "print the modification time in Go `layout`")
+	fs.BoolVar(&opts.watch, "watch", false, "print the tree again whenever it changes")
+	fs.BoolVar(&opts.events, "events", false, "watch and print only added, removed and changed entries")
+	fs.BoolVar(&opts.stream, "stream", false, "print while reading, for directories too large to hold in memory")
+	fs.BoolVar(&opts.unsorted, "U", false, "stream entries unsorted, in directory order")
+	fs.BoolVar(&cmd.help, "help", false, "print this help")
+	fs.BoolVar(&cmd.version, "version", false, "print the version")
+	return fs
+}
+
+func printUsage(out io.Writer) {
+	fs := newFlagSet(&command{})
+	fs.SetOutput(out)
+	fmt.Fprint(out, usageHeader)
+	fs.PrintDefaults()
+	fmt.Fprint(out, usageFooter)
+}
+
+// errVersion and flag.ErrHelp are returned by parseArgs for --version and
+// --help, which print something instead of running.
+var errVersion = errors.New("version requested")
+
+// parseArgs accepts flags before, between and after positional arguments.
+func parseArgs(args []string) (command, error) {
+	var cmd command
+	fs := newFlagSet(&cmd)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return cmd, err
+		}
+		rest := fs.Args()
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			cmd.args = append(cmd.args, rest...)
+			break
+		}
+		if len(rest) == 0 {
+			break
+		}
+		cmd.args = append(cmd.args, rest[0])
+		args = rest[1:]
+	}
+
+	opts := &cmd.opts
+	switch {
+	case cmd.help:
+		return cmd, flag.ErrHelp
+	case cmd.version:
+		return cmd, errVersion
+	}
+	if cmd.hash && opts.checksum == "" {
+		opts.checksum = checksumSHA256
+	}
+	if cmd.dates && opts.timeFormat == "" {
+		opts.timeFormat = defaultTimeFormat
+	}
+	switch {
+	case opts.sortBy != "":
+	case cmd.byVersion:
+		opts.sortBy = tree.SortVersion
+	case cmd.byMtime:
+		opts.sortBy = tree.SortMtime
+	}
+	if opts.dupes {
+		opts.printFiles = true
+	}
+	if opts.events {
+		opts.watch = true
+	}
+	if opts.unsorted {
+		opts.stream = true
+	}
+
+	if len(cmd.args) < 1 {
+		return cmd, fmt.Errorf("path is required")
+	}
+	switch opts.format {
+	case formatText, formatJSON, formatYAML, formatHTML, formatMarkdown, formatMarkdownFenced:
+	default:
+		return cmd, fmt.Errorf("unknown format %q", opts.format)
+	}
+	switch opts.sortBy {
+	case "", tree.SortName, tree.SortVersion, tree.SortSize, tree.SortMtime:
+	default:
+		return cmd, fmt.Errorf("unknown sort %q", opts.sortBy)
+	}
+	switch opts.charset {
+	case charsetUTF8, charsetASCII:
+	default:
+		return cmd, fmt.Errorf("unknown charset %q", opts.charset)
+	}
+	if opts.stream {
+		if err := checkStream(cmd); err != nil {
+			return cmd, err
+		}
+	}
+	return cmd, nil
+}
+
+// checkStream rejects the flags that need a directory, or the whole tree,
+// in memory before printing.
+func checkStream(cmd command) error {
+	opts := cmd.opts
+	unsupported := []struct {
+		set  bool
+		flag string
+	}{
+		{opts.format != formatText, "-format " + opts.format},
+		{opts.sortBy != "" && opts.sortBy != tree.SortName, "--sort " + opts.sortBy},
+		{opts.dirsFirst, "--dirsfirst"},
+		{opts.reverse, "-r"},
+		{opts.gitignore, "--gitignore"},
+		{opts.du, "--du"},
+		{opts.follow, "-l"},
+		{opts.checksum != "", "--checksum"},
+		{opts.dupes, "--dupes"},
+		{opts.perms || opts.owner || opts.group || opts.timeFormat != "", "-p, -u, -g and -D"},
+		{opts.watch, "--watch"},
+	}
+	for _, u := range unsupported {
+		if u.set {
+			return fmt.Errorf("--stream does not support %s", u.flag)
+		}
+	}
+	return nil
+}
+
+func setOption(opts *options, name, value string) error {
+	switch name {
+	case "--checksum":
+		if _, err := newHash(value); err != nil {
+			return err
+		}
+		opts.checksum = value
+	case "-L":
+		depth, err := strconv.Atoi(value)
+		if err != nil || depth < 1 {
+			return fmt.Errorf("invalid depth %q", value)
+		}
+		opts.maxDepth = depth
+	case "-j":
+		workers, err := strconv.Atoi(value)
+		if err != nil || workers < 1 {
+			return fmt.Errorf("invalid number of workers %q", value)
+		}
+		opts.workers = workers
+	case "-I", "-P":
+		if _, err := filepath.Match(value, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", value, err)
+		}
+		if name == "-I" {
+			opts.exclude = append(opts.exclude, value)
+		} else {
+			opts.include = append(opts.include, value)
+		}
+	}
+	return nil
+}
+
+func run(out io.Writer, args []string, opts options) error {
+	switch args[0] {
+	case "snapshot":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: snapshot <dir> <file> [--hash]")
+		}
+		return saveSnapshot(args[1], args[2], opts)
+	case "diff":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: diff <snapshot> <dir or snapshot>")
+		}
+		return diffTrees(out, args[1], args[2], opts)
+	case "serve":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: serve <addr> <dir>")
+		}
+		fmt.Fprintf(out, "serving %s on %s\n", args[2], args[1])
+		return http.ListenAndServe(args[1], newTreeServer(args[2], opts))
+	}
+	if opts.watch {
+		if len(args) != 1 {
+			return fmt.Errorf("--watch takes a single path")
+		}
+		return watchTree(out, args[0], opts, nil)
+	}
+
+	// with several roots each text tree is introduced by its path, the
+	// other formats are written one after another
+	var walkErr walkErrors
+	for i, root := range args {
+		if len(args) > 1 && opts.format == formatText {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprintln(out, root)
+		}
+		err := renderTree(out, root, opts)
+		if errs, ok := err.(walkErrors); ok {
+			walkErr = append(walkErr, errs...)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(walkErr) > 0 {
+		return walkErr
+	}
+	return nil
+}
+
+// runMain runs the command line args and returns the exit status.
+func runMain(args []string, stdout, stderr io.Writer) int {
+	cmd, err := parseArgs(args)
+	switch {
+	case err == flag.ErrHelp:
+		printUsage(stdout)
+		return exitOK
+	case err == errVersion:
+		fmt.Fprintln(stdout, "tree", version)
+		return exitOK
+	case err != nil:
+		fmt.Fprintf(stderr, "tree: %v\nTry 'tree --help' for more information.\n", err)
+		return exitUsage
+	}
+
+	out := stdout
+	var file *os.File
+	if cmd.output != "" {
+		if file, err = os.Create(cmd.output); err != nil {
+			fmt.Fprintln(stderr, "tree:", err)
+			return exitFailure
+		}
+		out = file
+	}
+
+	err = run(out, cmd.args, cmd.opts)
+	if file != nil {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	if errs, ok := err.(walkErrors); ok {
+		// the tree is complete apart from the marked entries
+		for _, e := range errs {
+			fmt.Fprintln(stderr, "tree:", e)
+		}
+		return exitIncomplete
+	}
+	switch {
+	case err == errTreesDiffer:
+		return exitDiffer
+	case err != nil:
+		fmt.Fprintln(stderr, "tree:", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func main() {
+	os.Exit(runMain(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-webservices/cmd/hw1_tree/tree"
+)
+
+func runArgs(args ...string) (int, string, string) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	code := runMain(args, stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestParseArgs(t *testing.T) {
+	for _, args := range [][]string{
+		{"testdata", "-f"},
+		{"-f", "testdata"},
+		{"--format=text", "testdata", "-L", "9", "-f"},
+	} {
+		code, out, errOut := runArgs(args...)
+		if code != exitOK || out != testFullResult {
+			t.Errorf("%q: exit %d, stderr %q, results not match\nGot:\n%v\nExpected:\n%v",
+				args, code, errOut, out, testFullResult)
+		}
+	}
+
+	cmd, err := parseArgs([]string{"-t", "a", "--hash", "--", "-f", "b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(cmd.args, " ") != "a -f b" || cmd.opts.printFiles ||
+		cmd.opts.sortBy != tree.SortMtime || cmd.opts.checksum != checksumSHA256 {
+		t.Errorf("unexpected command %+v", cmd)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	cases := []struct {
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"--help"}, exitOK, "exit status:", ""},
+		{[]string{"--version"}, exitOK, "tree " + version + "\n", ""},
+		{[]string{}, exitUsage, "", "path is required"},
+		{[]string{"-f", "--bogus", "testdata"}, exitUsage, "", "-bogus"},
+		{[]string{"-L", "zero", "testdata"}, exitUsage, "", "invalid depth"},
+		{[]string{"--charset", "ebcdic", "testdata"}, exitUsage, "", "unknown charset"},
+		{[]string{"-U", "--du", "testdata"}, exitUsage, "", "--stream does not support --du"},
+		{[]string{"testdata/missing"}, exitFailure, "", "missing"},
+		{[]string{"diff", "testdata", "testdata/static"}, exitDiffer, "removed", ""},
+	}
+	for _, c := range cases {
+		code, out, errOut := runArgs(c.args...)
+		if code != c.code || !strings.Contains(out, c.stdout) || !strings.Contains(errOut, c.stderr) {
+			t.Errorf("%q: got exit %d, stdout %q, stderr %q", c.args, code, out, errOut)
+		}
+	}
+
+	defer denyDirs("project")()
+	code, out, errOut := runArgs("testdata")
+	if code != exitIncomplete || !strings.Contains(out, "project [error opening dir]") ||
+		!strings.Contains(errOut, "permission denied") {
+		t.Errorf("unreadable directory: got exit %d, stdout %q, stderr %q", code, out, errOut)
+	}
+}
+
+func TestMultipleRoots(t *testing.T) {
+	expected := `testdata/project
+├───file.txt (19b)
+└───gopher.png (70372b)
+
+testdata/zline
+├───empty.txt (empty)
+└───lorem
+	├───dolor.txt (empty)
+	├───gopher.png (70372b)
+	└───ipsum
+		└───gopher.png (70372b)
+`
+	code, out, errOut := runArgs("testdata/project", "-f", "testdata/zline")
+	if code != exitOK {
+		t.Fatalf("exit %d: %s", code, errOut)
+	}
+	if out != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out, expected)
+	}
+}
+
+func TestOutputFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tree.txt")
+	code, out, errOut := runArgs("-o", file, "testdata/zline", "--charset=ascii", "-f")
+	if code != exitOK || out != "" {
+		t.Fatalf("exit %d, stdout %q, stderr %q", code, out, errOut)
+	}
+	written, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `|--empty.txt (empty)
+\--lorem
+	|--dolor.txt (empty)
+	|--gopher.png (70372b)
+	\--ipsum
+		\--gopher.png (70372b)
+`
+	if string(written) != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", string(written), expected)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"archive/tar"
+	"os"
+	"strings"
+)
+
+const defaultTimeFormat = "Jan _2 15:04"
+
+// fileOwner resolves owner and group names from the system for files on disk
+// and from the header for tar entries. Unknown ones are printed as "?".
+func fileOwner(info os.FileInfo) (owner, group string) {
+	if hdr, ok := info.Sys().(*tar.Header); ok {
+		owner, group = hdr.Uname, hdr.Gname
+	} else if o, g, ok := systemOwner(info); ok {
+		owner, group = o, g
+	}
+	if owner == "" {
+		owner = "?"
+	}
+	if group == "" {
+		group = "?"
+	}
+	return owner, group
+}
+
+// entryColumns is printed before the name in brackets, like the -p, -u, -g
+// and -D columns of GNU tree, followed by the checksum if there is one.
+func entryColumns(n *node, opts options) string {
+	var fields []string
+	if opts.perms {
+		fields = append(fields, n.info.Mode().String())
+	}
+	if opts.owner || opts.group {
+		owner, group := fileOwner(n.info)
+		if opts.owner {
+			fields = append(fields, owner)
+		}
+		if opts.group {
+			fields = append(fields, group)
+		}
+	}
+	if opts.timeFormat != "" {
+		fields = append(fields, n.info.ModTime().Format(opts.timeFormat))
+	}
+	if n.sum != "" {
+		fields = append(fields, n.sum)
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(fields, " ") + "] "
+}
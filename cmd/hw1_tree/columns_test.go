@@ -0,0 +1,105 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTreeColumns(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"bin/run.sh": "#!/bin/sh\n",
+		"README":     "",
+	})
+	mtime := time.Date(2021, 12, 26, 10, 30, 0, 0, time.UTC)
+	for name, mode := range map[string]os.FileMode{"bin": 0750, "bin/run.sh": 0755, "README": 0600} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	date := mtime.Local().Format("2006-01-02 15:04")
+	expected := `├───[-rw------- ` + date + `] README (empty)
+└───[drwxr-x--- ` + date + `] bin
+	└───[-rwxr-xr-x ` + date + `] run.sh (10b)
+`
+	out := new(bytes.Buffer)
+	opts := options{printFiles: true, perms: true, timeFormat: "2006-01-02 15:04"}
+	if err := renderTree(out, root, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+}
+
+func writeTar(t *testing.T, archive string, headers []*tar.Header) {
+	file, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(file)
+	for _, hdr := range headers {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			if _, err := tw.Write([]byte("ok")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+}
+
+func TestTreeOwnerColumns(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "owned.tar")
+	writeTar(t, archive, []*tar.Header{
+		{Name: "www/", Typeflag: tar.TypeDir, Mode: 0755, Uname: "deploy", Gname: "www-data"},
+		{Name: "www/index.html", Typeflag: tar.TypeReg, Mode: 0644, Size: 2, Uname: "root", Gname: "www-data"},
+	})
+
+	expected := `└───[deploy www-data] www
+	└───[root www-data] index.html (2b)
+`
+	out := new(bytes.Buffer)
+	if err := renderTree(out, archive, options{printFiles: true, owner: true, group: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+}
+
+func TestTreeOwnerImpliedDirs(t *testing.T) {
+	// neither the root nor www has a header of its own
+	archive := filepath.Join(t.TempDir(), "implied.tar")
+	writeTar(t, archive, []*tar.Header{
+		{Name: "www/index.html", Typeflag: tar.TypeReg, Mode: 0644, Size: 2, Uname: "root", Gname: "www-data"},
+	})
+
+	expected := `└───[? ?] www
+	└───[root www-data] index.html (2b)
+`
+	out := new(bytes.Buffer)
+	if err := renderTree(out, archive, options{printFiles: true, owner: true, group: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+	if err := renderTree(new(bytes.Buffer), archive, options{owner: true, format: formatJSON}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+package main
+
+import "go-webservices/cmd/hw1_tree/tree"
+
+// walkErrors collects every directory that could not be read during a walk,
+// in the order they appear in the output.
+type walkErrors = tree.Errors
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var errDenied = errors.New("permission denied")
+
+// denyDirs makes readDir fail for the given directories until the returned
+// function restores it.
+func denyDirs(dirs ...string) func() {
+	orig := readDir
+	readDir = func(fsys fs.FS, name string) ([]os.FileInfo, error) {
+		for _, d := range dirs {
+			if name == d {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: errDenied}
+			}
+		}
+		return orig(fsys, name)
+	}
+	return func() { readDir = orig }
+}
+
+const testErrorResult = `├───project [error opening dir]
+├───static
+│	├───a_lorem
+│	│	└───ipsum
+│	├───css
+│	├───html
+│	├───js
+│	└───z_lorem [error opening dir]
+└───zline
+	└───lorem
+		└───ipsum
+`
+
+func TestTreeErrors(t *testing.T) {
+	defer denyDirs("project", "static/z_lorem")()
+
+	for _, workers := range []int{1, 4} {
+		out := new(bytes.Buffer)
+		err := renderTree(out, "testdata", options{workers: workers})
+		if out.String() != testErrorResult {
+			t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out.String(), testErrorResult)
+		}
+		errs, ok := err.(walkErrors)
+		if !ok || len(errs) != 2 {
+			t.Fatalf("expected 2 collected errors, got %#v", err)
+		}
+		if !errors.Is(errs[0], errDenied) || errs[0].(*os.PathError).Path != filepath.Join("testdata", "project") {
+			t.Errorf("unexpected first error: %v", errs[0])
+		}
+	}
+}
+
+func TestTreeStrict(t *testing.T) {
+	defer denyDirs("static/z_lorem")()
+
+	out := new(bytes.Buffer)
+	err := renderTree(out, "testdata", options{printFiles: true, strict: true})
+	if !errors.Is(err, errDenied) {
+		t.Errorf("expected permission error, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no output in strict mode, got:\n%v", out.String())
+	}
+}
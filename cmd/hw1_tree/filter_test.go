@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTreeFilters(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     options
+		expected string
+	}{
+		{
+			name: "depth",
+			opts: options{printFiles: true, maxDepth: 1},
+			expected: `├───project
+├───static
+├───zline
+└───zzfile.txt (empty)
+`,
+		},
+		{
+			name: "exclude",
+			opts: options{printFiles: true, exclude: []string{"*.png", "static"}},
+			expected: `├───project
+│	└───file.txt (19b)
+├───zline
+│	├───empty.txt (empty)
+│	└───lorem
+│		├───dolor.txt (empty)
+│		└───ipsum
+└───zzfile.txt (empty)
+`,
+		},
+		{
+			name: "include",
+			opts: options{printFiles: true, maxDepth: 2, include: []string{"*.css", "*.js"}},
+			expected: `├───project
+├───static
+│	├───a_lorem
+│	├───css
+│	├───html
+│	├───js
+│	└───z_lorem
+└───zline
+	└───lorem
+`,
+		},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		if err := renderTree(out, "testdata", c.opts); err != nil {
+			t.Errorf("[%s] unexpected error: %v", c.name, err)
+		}
+		if out.String() != c.expected {
+			t.Errorf("[%s] results not match\nGot:\n%v\nExpected:\n%v", c.name, out.String(), c.expected)
+		}
+	}
+}
+
+func TestTreeGitignore(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":            "# build output\n*.log\n/vendor/\nnode_modules\n!keep.log\n",
+		".git/HEAD":             "ref: refs/heads/master\n",
+		"app.log":               "",
+		"keep.log":              "",
+		"main.go":               "package main\n",
+		"vendor/lib.go":         "",
+		"web/node_modules/x.js": "",
+		"web/vendor/y.js":       "",
+		"web/.gitignore":        "*.map\n!important.log\n",
+		"web/app.js.map":        "",
+		"web/important.log":     "",
+		"web/docs/readme.md":    "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := `├───.gitignore (53b)
+├───keep.log (empty)
+├───main.go (13b)
+└───web
+	├───.gitignore (21b)
+	├───docs
+	│	└───readme.md (empty)
+	├───important.log (empty)
+	└───vendor
+		└───y.js (empty)
+`
+	out := new(bytes.Buffer)
+	if err := renderTree(out, root, options{printFiles: true, gitignore: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+}
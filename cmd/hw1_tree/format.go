@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-webservices/cmd/hw1_tree/tree"
+)
+
+const (
+	typeFile = "file"
+	typeDir  = "directory"
+	typeLink = "link"
+)
+
+type document struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	Size     int64       `json:"size"`
+	Mode     string      `json:"mode"`
+	ModTime  time.Time   `json:"mtime"`
+	Owner    string      `json:"owner,omitempty"`
+	Group    string      `json:"group,omitempty"`
+	Target   string      `json:"target,omitempty"`
+	Dangling bool        `json:"dangling,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Checksum string      `json:"checksum,omitempty"`
+	Sum      string      `json:"sum,omitempty"`
+	Total    *total      `json:"total,omitempty"`
+	Children []*document `json:"children,omitempty"`
+}
+
+type total struct {
+	Size        int64 `json:"size"`
+	Directories int   `json:"directories"`
+	Files       int   `json:"files"`
+}
+
+func newDocument(name string, n *node, opts options) *document {
+	doc := &document{
+		Name:    name,
+		Type:    typeFile,
+		Size:    n.info.Size(),
+		Mode:    n.info.Mode().String(),
+		ModTime: n.info.ModTime(),
+	}
+	if link, ok := n.info.(*tree.Link); ok {
+		doc.Type = typeLink
+		doc.Target = link.Target
+		doc.Dangling = link.Dangling()
+	} else if n.info.IsDir() {
+		doc.Type = typeDir
+	}
+	if n.err != nil {
+		doc.Error = n.err.Error()
+	}
+	if n.sum != "" {
+		doc.Checksum, doc.Sum = opts.checksum, n.sum
+	}
+	if opts.owner || opts.group {
+		owner, group := fileOwner(n.info)
+		if opts.owner {
+			doc.Owner = owner
+		}
+		if opts.group {
+			doc.Group = group
+		}
+	}
+	if n.info.IsDir() && opts.du {
+		doc.Total = &total{Size: n.total.size, Directories: n.total.dirs, Files: n.total.files}
+	}
+	for _, child := range n.children {
+		doc.Children = append(doc.Children, newDocument(child.info.Name(), child, opts))
+	}
+	return doc
+}
+
+func printJSON(out io.Writer, path string, root *node, opts options) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newDocument(path, root, opts))
+}
+
+func printYAML(out io.Writer, path string, root *node, opts options) error {
+	return writeYAML(out, newDocument(path, root, opts), "")
+}
+
+type yamlField struct {
+	key, value string
+}
+
+// writeYAML emits doc as a block mapping. Strings are always double-quoted so
+// names such as "true" or "007" survive a round trip unchanged.
+func writeYAML(out io.Writer, doc *document, indent string) error {
+	fields := []yamlField{
+		{"name", strconv.Quote(doc.Name)},
+		{"type", doc.Type},
+		{"size", strconv.FormatInt(doc.Size, 10)},
+		{"mode", strconv.Quote(doc.Mode)},
+		{"mtime", doc.ModTime.Format(time.RFC3339)},
+	}
+	if doc.Owner != "" {
+		fields = append(fields, yamlField{"owner", strconv.Quote(doc.Owner)})
+	}
+	if doc.Group != "" {
+		fields = append(fields, yamlField{"group", strconv.Quote(doc.Group)})
+	}
+	if doc.Type == typeLink {
+		fields = append(fields,
+			yamlField{"target", strconv.Quote(doc.Target)},
+			yamlField{"dangling", strconv.FormatBool(doc.Dangling)})
+	}
+	if doc.Error != "" {
+		fields = append(fields, yamlField{"error", strconv.Quote(doc.Error)})
+	}
+	if doc.Sum != "" {
+		fields = append(fields, yamlField{"checksum", doc.Checksum}, yamlField{"sum", doc.Sum})
+	}
+	for i, f := range fields {
+		lead := indent
+		if i == 0 && indent != "" {
+			lead = indent[:len(indent)-2] + "- "
+		}
+		if _, err := fmt.Fprintf(out, "%s%s: %s\n", lead, f.key, f.value); err != nil {
+			return err
+		}
+	}
+	if doc.Total != nil {
+		_, err := fmt.Fprintf(out, "%stotal:\n%s  size: %d\n%s  directories: %d\n%s  files: %d\n",
+			indent, indent, doc.Total.Size, indent, doc.Total.Directories, indent, doc.Total.Files)
+		if err != nil {
+			return err
+		}
+	}
+	if len(doc.Children) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(out, "%schildren:\n", indent); err != nil {
+		return err
+	}
+	childIndent := indent + strings.Repeat(" ", 4)
+	for _, child := range doc.Children {
+		if err := writeYAML(out, child, childIndent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTreeJSON(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := renderTree(out, "testdata", options{printFiles: true, format: formatJSON})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var root document
+	if err := json.Unmarshal(out.Bytes(), &root); err != nil {
+		t.Fatalf("output is not valid json: %v", err)
+	}
+	if root.Name != "testdata" || root.Type != typeDir {
+		t.Errorf("unexpected root: %s (%s)", root.Name, root.Type)
+	}
+	if len(root.Children) != 4 {
+		t.Fatalf("expected 4 children, got %d", len(root.Children))
+	}
+	project := root.Children[0]
+	if project.Name != "project" || len(project.Children) != 2 {
+		t.Fatalf("unexpected project node: %+v", project)
+	}
+	file := project.Children[0]
+	if file.Name != "file.txt" || file.Type != typeFile || file.Size != 19 || !strings.HasPrefix(file.Mode, "-rw") {
+		t.Errorf("unexpected file node: %+v", file)
+	}
+	if file.ModTime.IsZero() {
+		t.Errorf("mtime is not set")
+	}
+}
+
+func TestTreeYAML(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := renderTree(out, "testdata/zline", options{printFiles: false, format: formatYAML})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if i := strings.Index(line, "name: "); i >= 0 {
+			names = append(names, line[:i]+line[i+len("name: "):])
+		}
+	}
+	expected := []string{
+		`"testdata/zline"`,
+		`  - "lorem"`,
+		`      - "ipsum"`,
+	}
+	if strings.Join(names, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", strings.Join(names, "\n"), strings.Join(expected, "\n"))
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import "go-webservices/cmd/hw1_tree/tree"
+
+// displayName is the entry name as printed, with the target appended to links
+// and a marker on directories that could not be read.
+func displayName(n *node) string {
+	name := n.info.Name()
+	if link, ok := n.info.(*tree.Link); ok {
+		name += " -> " + link.Target
+		switch {
+		case link.Dangling():
+			name += " [dangling]"
+		case n.recursive:
+			name += " [recursive, not followed]"
+		}
+	}
+	if n.err != nil {
+		name += " [error opening dir]"
+	}
+	return name
+}
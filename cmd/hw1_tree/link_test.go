@@ -0,0 +1,168 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func makeLinkTree(t *testing.T) string {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "assets", "img"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "assets", "img", "logo.png"), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "site"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"site/static":   "../assets",
+		"site/logo.png": "../assets/img/logo.png",
+		"site/missing":  "nowhere.txt",
+		"assets/loop":   "..",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	return root
+}
+
+func TestTreeLinks(t *testing.T) {
+	root := makeLinkTree(t)
+	cases := []struct {
+		name     string
+		opts     options
+		expected string
+	}{
+		{
+			name: "not followed",
+			opts: options{printFiles: true},
+			expected: `├───assets
+│	├───img
+│	│	└───logo.png (3b)
+│	└───loop -> ..
+└───site
+	├───logo.png -> ../assets/img/logo.png (3b)
+	├───missing -> nowhere.txt [dangling]
+	└───static -> ../assets
+`,
+		},
+		{
+			name: "followed",
+			opts: options{printFiles: false, follow: true},
+			expected: `├───assets
+│	├───img
+│	└───loop -> .. [recursive, not followed]
+└───site
+	└───static -> ../assets
+		├───img
+		└───loop -> .. [recursive, not followed]
+`,
+		},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		if err := renderTree(out, root, c.opts); err != nil {
+			t.Errorf("[%s] unexpected error: %v", c.name, err)
+		}
+		if out.String() != c.expected {
+			t.Errorf("[%s] results not match\nGot:\n%v\nExpected:\n%v", c.name, out.String(), c.expected)
+		}
+	}
+}
+
+// tarLinkTree packs the tree of makeLinkTree, links included.
+func tarLinkTree(t *testing.T, root string) string {
+	var headers []*tar.Header
+	var contents [][]byte
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+		target, _ := os.Readlink(path)
+		hdr, err := tar.FileInfoHeader(info, target)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		hdr.Name = filepath.ToSlash(rel)
+		var data []byte
+		if info.Mode().IsRegular() {
+			if data, err = ioutil.ReadFile(path); err != nil {
+				return err
+			}
+		}
+		headers = append(headers, hdr)
+		contents = append(contents, data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "links.tar")
+	file, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	tw := tar.NewWriter(file)
+	for i, hdr := range headers {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(contents[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+func TestTarLinks(t *testing.T) {
+	root := makeLinkTree(t)
+	archive := tarLinkTree(t, root)
+	for _, opts := range []options{
+		{printFiles: true},
+		{printFiles: false, follow: true},
+		{printFiles: true, follow: true, checksum: checksumCRC32},
+	} {
+		expected, got := new(bytes.Buffer), new(bytes.Buffer)
+		if err := renderTree(expected, root, opts); err != nil {
+			t.Fatal(err)
+		}
+		if err := renderTree(got, archive, opts); err != nil {
+			t.Errorf("%+v: unexpected error: %v", opts, err)
+		}
+		if got.String() != expected.String() {
+			t.Errorf("%+v: results not match\nGot:\n%v\nExpected:\n%v", opts, got.String(), expected.String())
+		}
+	}
+
+	// links leaving the archive lead nowhere
+	fsys := &tarFS{files: map[string]*tarFile{
+		".":   {name: ".", mode: fs.ModeDir},
+		"up":  {name: "up", mode: fs.ModeSymlink, target: "../etc"},
+		"abs": {name: "abs", mode: fs.ModeSymlink, target: "/etc"},
+		"a":   {name: "a", mode: fs.ModeSymlink, target: "b"},
+		"b":   {name: "b", mode: fs.ModeSymlink, target: "a"},
+	}}
+	for _, name := range []string{"up", "abs", "a"} {
+		if f := fsys.lookup(name, true); f != nil {
+			t.Errorf("%s: expected no target, got %s", name, f.name)
+		}
+		if f := fsys.lookup(name, false); f == nil || f.name != name {
+			t.Errorf("%s: expected the link itself, got %v", name, f)
+		}
+	}
+}
@@ -3,23 +3,69 @@ package main
 import (
 	"fmt"
 	"io"
-	"io/ioutil"
+	"io/fs"
 	"os"
-	"path/filepath"
-	"sort"
-	"strconv"
+
+	"go-webservices/cmd/hw1_tree/tree"
 )
 
-func printFile(out io.Writer, file os.FileInfo, printFiles bool, prefix string, header string) error {
-	fileSize := file.Size()
-	var size string
-	if fileSize <= 0 {
-		size = "empty"
-	} else {
-		size = strconv.Itoa(int(fileSize)) + "b"
-	}
-	if printFiles {
-		_, err := fmt.Fprintf(out, "%s%s%s (%s)\n", prefix, header, file.Name(), size)
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+type options struct {
+	printFiles bool
+	format     string
+	maxDepth   int
+	include    []string
+	exclude    []string
+	gitignore  bool
+	workers    int
+	du         bool
+	human      bool
+	report     bool
+	follow     bool
+	strict     bool
+	sortBy     string
+	dirsFirst  bool
+	reverse    bool
+	baseURL    string
+	checksum   string
+	dupes      bool
+	perms      bool
+	owner      bool
+	group      bool
+	timeFormat string
+	watch      bool
+	events     bool
+	charset    string
+	stream     bool
+	unsorted   bool
+}
+
+type node struct {
+	info     os.FileInfo
+	children []*node
+	err      error
+	// recursive marks a directory link back to one of its own ancestors
+	recursive bool
+	// total covers the whole subtree once the walk has finished
+	total totals
+	// sum is the hex checksum of a file, set by hashTree
+	sum string
+}
+
+type textRenderer struct {
+	out  io.Writer
+	opts options
+}
+
+func (r *textRenderer) printFile(file *node, pos tree.Position) error {
+	if r.opts.printFiles {
+		_, err := fmt.Fprintf(r.out, "%s%s%s%s%s\n", pos.Prefix, pos.Header(),
+			entryColumns(file, r.opts), displayName(file), entryDetails(file, r.opts))
 		if err != nil {
 			return err
 		}
@@ -27,88 +73,156 @@ func printFile(out io.Writer, file os.FileInfo, printFiles bool, prefix string,
 	return nil
 }
 
-func printDir(out io.Writer, dir os.FileInfo, prefix string, header string) error {
-	_, err := fmt.Fprintf(out, "%s%s%s\n", prefix, header, dir.Name())
+func (r *textRenderer) printDir(dir *node, pos tree.Position) error {
+	_, err := fmt.Fprintf(r.out, "%s%s%s%s%s\n", pos.Prefix, pos.Header(),
+		entryColumns(dir, r.opts), displayName(dir), entryDetails(dir, r.opts))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func header(lastEntry bool) string {
-	header := "├───"
-	lastHeader := "└───"
-	head := header
-	if lastEntry {
-		head = lastHeader
+func (r *textRenderer) closeDir(dir *node, pos tree.Position) error {
+	return nil
+}
+
+func (r *textRenderer) begin(root *node) error {
+	return nil
+}
+
+func (r *textRenderer) end(root *node) error {
+	if r.opts.report {
+		return printReport(r.out, root, r.opts)
 	}
+	return nil
+}
+
+// readDir lists directories for every walk, tests replace it to fail some.
+var readDir = tree.ReadDir
 
-	return head
+func (o options) treeOptions() tree.Options {
+	return tree.Options{
+		Files:       o.printFiles,
+		MaxDepth:    o.maxDepth,
+		Exclude:     o.exclude,
+		Include:     o.include,
+		Gitignore:   o.gitignore,
+		FollowLinks: o.follow,
+		Strict:      o.strict,
+		Workers:     o.workers,
+		SortBy:      o.sortBy,
+		DirsFirst:   o.dirsFirst,
+		Reverse:     o.reverse,
+		ReadDir:     readDir,
+	}
 }
 
-func cleanupList(list []os.FileInfo, printFiles bool) (result []os.FileInfo) {
-	if !printFiles {
-		for _, f := range list {
-			if f.IsDir() {
-				result = append(result, f)
-			}
+// newNode copies a tree read by package tree. Every directory starts out
+// with the totals of the files directly inside it.
+func newNode(t *tree.Node) *node {
+	n := &node{info: t.Info, err: t.Err, recursive: t.Recursive}
+	for _, child := range t.Children {
+		n.children = append(n.children, newNode(child))
+		if !child.Info.IsDir() {
+			n.total.files++
+			n.total.size += child.Info.Size()
 		}
-	} else {
-		result = append(result, list...)
 	}
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].Name() < result[j].Name()
-	})
-	return
+	return n
+}
+
+func readTree(src source, opts options) (*node, error) {
+	topts := opts.treeOptions()
+	// --du needs every file of the whole subtree, deeper levels and files
+	// that are not printed are dropped after summing
+	if opts.du {
+		topts.MaxDepth = 0
+		topts.Files = true
+	}
+	t, err := src.treeSource().Read(topts)
+	if t == nil {
+		return nil, err
+	}
+	root := newNode(t)
+	sumTotals(root)
+	if opts.du && opts.maxDepth > 0 {
+		pruneDepth(root, opts.maxDepth)
+	}
+	if opts.du && !opts.printFiles {
+		dropFiles(root)
+	}
+	return root, err
 }
 
-func walkDir(out io.Writer, path string, printFiles bool, prefix string) error {
-	entries, err := ioutil.ReadDir(path)
+func renderTree(out io.Writer, path string, opts options) error {
+	src, err := openSource(path)
 	if err != nil {
 		return err
 	}
-	cleanEntries := cleanupList(entries, printFiles)
-	for i, entry := range cleanEntries {
-		lastEntry := i == (len(cleanEntries) - 1)
-		if entry.IsDir() {
-			if err := printDir(out, entry, prefix, header(lastEntry)); err != nil {
-				return err
-			}
-			var newPrefix string
-			if lastEntry {
-				newPrefix = prefix + "\t"
-			} else {
-				newPrefix = prefix + "│\t"
-			}
-			newPath := filepath.Join(path, entry.Name())
-			if err = walkDir(out, newPath, printFiles, newPrefix); err != nil {
-				return err
-			}
-		} else {
-			err := printFile(out, entry, printFiles, prefix, header(lastEntry))
-			if err != nil {
-				return err
-			}
-		}
-	}
-	return nil
+	defer src.close()
+	return renderSource(out, path, src, opts)
 }
 
-func dirTree(out io.Writer, path string, printFiles bool) error {
-	_ = walkDir(out, path, printFiles, "")
+// renderFS prints a tree of fsys, such as an embed.FS, under the root name.
+func renderFS(out io.Writer, name string, fsys fs.FS, opts options) error {
+	return renderSource(out, name, source{fsys: fsys, name: name}, opts)
+}
 
-	return nil
+func renderSource(out io.Writer, name string, src source, opts options) error {
+	if opts.stream {
+		return streamTree(out, src, opts)
+	}
+	root, walkErr := readTree(src, opts)
+	if root == nil {
+		return walkErr
+	}
+	if err := renderNode(out, name, src, root, opts); err != nil {
+		return err
+	}
+	return walkErr
 }
 
-func main() {
-	out := os.Stdout
-	if !(len(os.Args) == 2 || len(os.Args) == 3) {
-		panic("usage go run main.go . [-f]")
+// renderNode writes an already walked tree in the format opts ask for.
+func renderNode(out io.Writer, name string, src source, root *node, opts options) error {
+	if opts.dupes {
+		if opts.checksum == "" {
+			opts.checksum = checksumSHA256
+		}
+		groups, err := findDupes(src, root, opts)
+		if err != nil {
+			return err
+		}
+		return printDupes(out, groups, opts)
+	}
+	if opts.checksum != "" {
+		if err := hashTree(src, root, opts, allFiles); err != nil {
+			return err
+		}
+	}
+
+	switch opts.format {
+	case formatJSON:
+		return printJSON(out, name, root, opts)
+	case formatYAML:
+		return printYAML(out, name, root, opts)
+	default:
+		return printRendered(newRenderer(out, opts), root, opts)
 	}
-	path := os.Args[1]
-	printFiles := len(os.Args) == 3 && os.Args[2] == "-f"
-	err := dirTree(out, path, printFiles)
+}
+
+// dirTree returns a walkErrors listing every directory it could not read,
+// those are still printed and marked inline.
+func dirTree(out io.Writer, path string, printFiles bool) error {
+	src, err := openSource(path)
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
+	defer src.close()
+	opts := options{printFiles: printFiles}
+	err = src.treeSource().Walk(opts.treeOptions(), func(t *tree.Node, pos tree.Position) error {
+		n := &node{info: t.Info, err: t.Err, recursive: t.Recursive}
+		_, err := fmt.Fprintf(out, "%s%s%s%s\n", pos.Prefix, pos.Header(), displayName(n), entryDetails(n, opts))
+		return err
+	})
+	return err
 }
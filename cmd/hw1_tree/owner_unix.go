@@ -0,0 +1,58 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+var (
+	namesMu sync.Mutex
+	users   = map[uint32]string{}
+	groups  = map[uint32]string{}
+)
+
+// lookupName caches uid and gid lookups, a tree tends to be owned by a handful
+// of accounts. Ids without a name are printed as numbers.
+func lookupName(cache map[uint32]string, id uint32, lookup func(string) (string, error)) string {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+	if name, ok := cache[id]; ok {
+		return name
+	}
+	name, err := lookup(strconv.FormatUint(uint64(id), 10))
+	if err != nil {
+		name = strconv.FormatUint(uint64(id), 10)
+	}
+	cache[id] = name
+	return name
+}
+
+func lookupUser(id string) (string, error) {
+	u, err := user.LookupId(id)
+	if err != nil {
+		return "", err
+	}
+	return u.Username, nil
+}
+
+func lookupGroup(id string) (string, error) {
+	g, err := user.LookupGroupId(id)
+	if err != nil {
+		return "", err
+	}
+	return g.Name, nil
+}
+
+func systemOwner(info os.FileInfo) (owner, group string, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", "", false
+	}
+	return lookupName(users, st.Uid, lookupUser), lookupName(groups, st.Gid, lookupGroup), true
+}
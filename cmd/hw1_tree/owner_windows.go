@@ -0,0 +1,8 @@
+package main
+
+import "os"
+
+// systemOwner has no uid and gid to report on windows.
+func systemOwner(info os.FileInfo) (owner, group string, ok bool) {
+	return "", "", false
+}
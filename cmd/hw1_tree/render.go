@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"strings"
+
+	"go-webservices/cmd/hw1_tree/tree"
+)
+
+const (
+	formatHTML           = "html"
+	formatMarkdown       = "markdown"
+	formatMarkdownFenced = "markdown-fenced"
+)
+
+// renderer receives the entries of a walked tree in output order. closeDir
+// follows the last entry below a directory, so nested formats can close it.
+type renderer interface {
+	begin(root *node) error
+	printDir(dir *node, pos tree.Position) error
+	closeDir(dir *node, pos tree.Position) error
+	printFile(file *node, pos tree.Position) error
+	end(root *node) error
+}
+
+const (
+	charsetUTF8  = "utf-8"
+	charsetASCII = "ascii"
+)
+
+func linesFor(opts options) *tree.Lines {
+	if opts.charset == charsetASCII {
+		return tree.ASCIILines
+	}
+	return tree.UTF8Lines
+}
+
+func newRenderer(out io.Writer, opts options) renderer {
+	switch opts.format {
+	case formatHTML:
+		return &htmlRenderer{out: out, opts: opts}
+	case formatMarkdown:
+		return &markdownRenderer{out: out, opts: opts}
+	case formatMarkdownFenced:
+		return &fencedRenderer{textRenderer{out: out, opts: opts}}
+	default:
+		return &textRenderer{out: out, opts: opts}
+	}
+}
+
+func printRendered(r renderer, root *node, opts options) error {
+	if err := r.begin(root); err != nil {
+		return err
+	}
+	if err := printTree(r, root, tree.Position{Lines: linesFor(opts)}); err != nil {
+		return err
+	}
+	return r.end(root)
+}
+
+func printTree(r renderer, dir *node, parent tree.Position) error {
+	for i, entry := range dir.children {
+		pos := parent.Child(entry.info.Name(), i == len(dir.children)-1)
+		if entry.info.IsDir() {
+			if err := r.printDir(entry, pos); err != nil {
+				return err
+			}
+			if err := printTree(r, entry, pos); err != nil {
+				return err
+			}
+			if err := r.closeDir(entry, pos); err != nil {
+				return err
+			}
+		} else {
+			err := r.printFile(entry, pos)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// entryURL links a file below opts.baseURL, or returns "" without one.
+func entryURL(opts options, rel string) string {
+	if opts.baseURL == "" {
+		return ""
+	}
+	segments := strings.Split(rel, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.TrimRight(opts.baseURL, "/") + "/" + strings.Join(segments, "/")
+}
+
+func entryDetails(n *node, opts options) string {
+	if n.info.IsDir() {
+		if opts.du {
+			return " (" + n.total.format(opts) + ")"
+		}
+		return ""
+	}
+	if link, ok := n.info.(*tree.Link); ok && link.Dangling() {
+		return ""
+	}
+	return " (" + formatSize(n.info.Size(), opts.human) + ")"
+}
+
+type fencedRenderer struct {
+	textRenderer
+}
+
+func (r *fencedRenderer) begin(root *node) error {
+	_, err := fmt.Fprintln(r.out, "```")
+	return err
+}
+
+func (r *fencedRenderer) end(root *node) error {
+	if err := r.textRenderer.end(root); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(r.out, "```")
+	return err
+}
+
+type markdownRenderer struct {
+	out  io.Writer
+	opts options
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`,
+)
+
+func (r *markdownRenderer) printEntry(n *node, pos tree.Position, suffix string) error {
+	name := markdownEscaper.Replace(displayName(n)) + suffix
+	if link := entryURL(r.opts, pos.Path); link != "" && !n.info.IsDir() {
+		name = "[" + name + "](" + link + ")"
+	}
+	indent := strings.Repeat("  ", pos.Depth-1)
+	_, err := fmt.Fprintf(r.out, "%s- %s%s%s\n", indent, markdownEscaper.Replace(entryColumns(n, r.opts)), name, entryDetails(n, r.opts))
+	return err
+}
+
+func (r *markdownRenderer) printDir(dir *node, pos tree.Position) error {
+	return r.printEntry(dir, pos, "/")
+}
+
+func (r *markdownRenderer) printFile(file *node, pos tree.Position) error {
+	return r.printEntry(file, pos, "")
+}
+
+func (r *markdownRenderer) closeDir(dir *node, pos tree.Position) error {
+	return nil
+}
+
+func (r *markdownRenderer) begin(root *node) error {
+	return nil
+}
+
+func (r *markdownRenderer) end(root *node) error {
+	if r.opts.report {
+		return printReport(r.out, root, r.opts)
+	}
+	return nil
+}
+
+// htmlRenderer writes nested lists with every directory in an open <details>
+// element, so the page starts expanded and each level can be folded. Only
+// files are linked, a link inside <summary> would swallow the toggle click.
+type htmlRenderer struct {
+	out  io.Writer
+	opts options
+}
+
+func (r *htmlRenderer) entryName(n *node, pos tree.Position) string {
+	name := html.EscapeString(displayName(n))
+	if link := entryURL(r.opts, pos.Path); link != "" && !n.info.IsDir() {
+		name = `<a href="` + html.EscapeString(link) + `">` + name + "</a>"
+	}
+	return html.EscapeString(entryColumns(n, r.opts)) + name + html.EscapeString(entryDetails(n, r.opts))
+}
+
+func (r *htmlRenderer) indent(pos tree.Position) string {
+	return strings.Repeat("  ", pos.Depth)
+}
+
+func (r *htmlRenderer) printDir(dir *node, pos tree.Position) error {
+	indent := r.indent(pos)
+	_, err := fmt.Fprintf(r.out, "%s<li><details open><summary>%s</summary>\n%s<ul>\n",
+		indent, r.entryName(dir, pos), indent)
+	return err
+}
+
+func (r *htmlRenderer) closeDir(dir *node, pos tree.Position) error {
+	indent := r.indent(pos)
+	_, err := fmt.Fprintf(r.out, "%s</ul>\n%s</details></li>\n", indent, indent)
+	return err
+}
+
+func (r *htmlRenderer) printFile(file *node, pos tree.Position) error {
+	_, err := fmt.Fprintf(r.out, "%s<li>%s</li>\n", r.indent(pos), r.entryName(file, pos))
+	return err
+}
+
+func (r *htmlRenderer) begin(root *node) error {
+	_, err := fmt.Fprintln(r.out, `<ul class="tree">`)
+	return err
+}
+
+func (r *htmlRenderer) end(root *node) error {
+	if _, err := fmt.Fprintln(r.out, "</ul>"); err != nil {
+		return err
+	}
+	if r.opts.report {
+		_, err := fmt.Fprintf(r.out, "<p>%s</p>\n", countListed(root).counts(r.opts.printFiles))
+		return err
+	}
+	return nil
+}
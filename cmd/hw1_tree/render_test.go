@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"go-webservices/cmd/hw1_tree/tree"
+)
+
+type fakeInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (f fakeInfo) Name() string       { return f.name }
+func (f fakeInfo) Size() int64        { return f.size }
+func (f fakeInfo) ModTime() time.Time { return time.Time{} }
+func (f fakeInfo) IsDir() bool        { return f.dir }
+func (f fakeInfo) Sys() interface{}   { return nil }
+
+func (f fakeInfo) Mode() os.FileMode {
+	if f.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+func TestTreeRenderers(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     options
+		expected string
+	}{
+		{
+			name: "markdown",
+			opts: options{printFiles: true, format: formatMarkdown, baseURL: "https://example.com/build/"},
+			expected: `- [empty.txt](https://example.com/build/empty.txt) (empty)
+- lorem/
+  - [dolor.txt](https://example.com/build/lorem/dolor.txt) (empty)
+  - [gopher.png](https://example.com/build/lorem/gopher.png) (70372b)
+  - ipsum/
+    - [gopher.png](https://example.com/build/lorem/ipsum/gopher.png) (70372b)
+`,
+		},
+		{
+			name: "markdown fenced",
+			opts: options{format: formatMarkdownFenced, report: true},
+			expected: "```" + `
+└───lorem
+	└───ipsum
+
+2 directories
+` + "```\n",
+		},
+		{
+			name: "html",
+			opts: options{printFiles: true, format: formatHTML, baseURL: "/files", maxDepth: 2},
+			expected: `<ul class="tree">
+  <li><a href="/files/empty.txt">empty.txt</a> (empty)</li>
+  <li><details open><summary>lorem</summary>
+  <ul>
+    <li><a href="/files/lorem/dolor.txt">dolor.txt</a> (empty)</li>
+    <li><a href="/files/lorem/gopher.png">gopher.png</a> (70372b)</li>
+    <li><details open><summary>ipsum</summary>
+    <ul>
+    </ul>
+    </details></li>
+  </ul>
+  </details></li>
+</ul>
+`,
+		},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		if err := renderTree(out, "testdata/zline", c.opts); err != nil {
+			t.Errorf("[%s] unexpected error: %v", c.name, err)
+		}
+		if out.String() != c.expected {
+			t.Errorf("[%s] results not match\nGot:\n%v\nExpected:\n%v", c.name, out.String(), c.expected)
+		}
+	}
+}
+
+func TestRendererEscaping(t *testing.T) {
+	opts := options{printFiles: true, baseURL: "/files"}
+	pos := tree.Position{Depth: 1, Path: "a b/<x>&_y.txt"}
+	file := &node{info: fakeInfo{name: "<x>&_y.txt", size: 3}}
+
+	out := new(bytes.Buffer)
+	if err := (&htmlRenderer{out: out, opts: opts}).printFile(file, pos); err != nil {
+		t.Fatal(err)
+	}
+	expected := `  <li><a href="/files/a%20b/%3Cx%3E&amp;_y.txt">&lt;x&gt;&amp;_y.txt</a> (3b)</li>` + "\n"
+	if out.String() != expected {
+		t.Errorf("html escaping\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+
+	out.Reset()
+	opts.baseURL = ""
+	if err := (&markdownRenderer{out: out, opts: opts}).printFile(file, pos); err != nil {
+		t.Fatal(err)
+	}
+	expected = `- \<x\>&\_y.txt (3b)` + "\n"
+	if out.String() != expected {
+		t.Errorf("markdown escaping\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+}
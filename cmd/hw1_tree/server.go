@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"html"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var contentTypes = map[string]string{
+	formatText:           "text/plain; charset=utf-8",
+	formatJSON:           "application/json",
+	formatYAML:           "application/yaml",
+	formatHTML:           "text/html; charset=utf-8",
+	formatMarkdown:       "text/markdown; charset=utf-8",
+	formatMarkdownFenced: "text/markdown; charset=utf-8",
+}
+
+var errOutsideRoot = errors.New("path is outside of the served root")
+
+// treeServer serves /tree?path=...&files=1&format=... for directories below
+// root. opts holds the defaults from the command line.
+type treeServer struct {
+	root string
+	opts options
+	mux  *http.ServeMux
+}
+
+func newTreeServer(root string, opts options) *treeServer {
+	s := &treeServer{root: root, opts: opts, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/tree", s.handleTree)
+	return s
+}
+
+func (s *treeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// resolve maps a slash-separated path from a request to a directory below
+// the root. ".." is refused outright and symlinks must not lead outside.
+func (s *treeServer) resolve(name string) (string, error) {
+	name = strings.Trim(name, "/")
+	if name == "" {
+		name = "."
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return "", errOutsideRoot
+		}
+	}
+	if !fs.ValidPath(path.Clean(name)) {
+		return "", errOutsideRoot
+	}
+	dir := filepath.Join(s.root, filepath.FromSlash(name))
+
+	// compare absolute paths, a relative root like "." is no prefix of
+	// the directories below it
+	root, err := evalAbs(s.root)
+	if err != nil {
+		return "", err
+	}
+	target, err := evalAbs(dir)
+	if err != nil {
+		return "", err
+	}
+	if target != root && !strings.HasPrefix(target, root+string(filepath.Separator)) {
+		return "", errOutsideRoot
+	}
+	return dir, nil
+}
+
+// evalAbs returns the absolute path of name with all symlinks resolved.
+func evalAbs(name string) (string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+func lastModified(n *node) time.Time {
+	latest := n.info.ModTime()
+	for _, child := range n.children {
+		if t := lastModified(child); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+func (s *treeServer) handleTree(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	opts := s.opts
+	opts.strict = false
+	// resolve only checks the requested directory, a followed link below it
+	// could list or hash whatever it points to outside the root
+	opts.follow = false
+	if files := query.Get("files"); files != "" {
+		opts.printFiles = files == "1" || files == "true"
+	}
+	if format := query.Get("format"); format != "" {
+		opts.format = format
+	}
+	if opts.format == "" {
+		opts.format = formatText
+	}
+	contentType, ok := contentTypes[opts.format]
+	if !ok {
+		http.Error(w, "unknown format "+opts.format, http.StatusBadRequest)
+		return
+	}
+
+	name := query.Get("path")
+	dir, err := s.resolve(name)
+	switch {
+	case errors.Is(err, errOutsideRoot):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case os.IsNotExist(err):
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	src, err := openSource(dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer src.close()
+	// report errors relative to the served root, not the local filesystem
+	name = "/" + strings.Trim(name, "/")
+	src.name = name
+	// unreadable directories are marked inline, as on the command line
+	root, err := readTree(src, opts)
+	if root == nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	body := new(bytes.Buffer)
+	if opts.format == formatHTML {
+		body.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>" +
+			html.EscapeString(name) + "</title></head><body>\n")
+	}
+	if err := renderNode(body, name, src, root, opts); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if opts.format == formatHTML {
+		body.WriteString("</body></html>\n")
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	http.ServeContent(w, r, "", lastModified(root), bytes.NewReader(body.Bytes()))
+}
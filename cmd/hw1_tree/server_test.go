@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func getTree(t *testing.T, srv *httptest.Server, query url.Values, header http.Header) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/tree?"+query.Encode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	return resp, body.String()
+}
+
+func TestServeTree(t *testing.T) {
+	srv := httptest.NewServer(newTreeServer("testdata", options{}))
+	defer srv.Close()
+
+	for _, files := range []bool{true, false} {
+		expected := new(bytes.Buffer)
+		if err := dirTree(expected, "testdata", files); err != nil {
+			t.Fatal(err)
+		}
+		query := url.Values{"format": {"text"}}
+		if files {
+			query.Set("files", "1")
+		}
+		resp, body := getTree(t, srv, query, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("files=%v: unexpected status %d: %s", files, resp.StatusCode, body)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+			t.Errorf("files=%v: unexpected content type %q", files, ct)
+		}
+		if body != expected.String() {
+			t.Errorf("files=%v: results not match\nGot:\n%v\nExpected:\n%v", files, body, expected.String())
+		}
+	}
+
+	resp, body := getTree(t, srv, url.Values{"path": {"project"}, "files": {"1"}, "format": {"json"}}, nil)
+	var doc document
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatalf("invalid json: %v\n%s", err, body)
+	}
+	if resp.Header.Get("Content-Type") != "application/json" || doc.Name != "/project" ||
+		len(doc.Children) != 2 || doc.Children[0].Name != "file.txt" {
+		t.Errorf("unexpected json document %+v", doc)
+	}
+
+	resp, body = getTree(t, srv, url.Values{"path": {"zline"}, "files": {"1"}, "format": {"html"}}, nil)
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") ||
+		!strings.Contains(body, "<title>/zline</title>") || !strings.Contains(body, `<ul class="tree">`) {
+		t.Errorf("unexpected html page:\n%s", body)
+	}
+}
+
+func TestServeRelativeRoot(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("testdata"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	srv := httptest.NewServer(newTreeServer(".", options{}))
+	defer srv.Close()
+	for _, name := range []string{"", "static", "/project/"} {
+		resp, body := getTree(t, srv, url.Values{"path": {name}, "files": {"1"}}, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%q: unexpected status %d: %s", name, resp.StatusCode, body)
+		}
+	}
+	if resp, _ := getTree(t, srv, url.Values{"path": {".."}}, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("..: expected status %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	writeFiles(t, root, map[string]string{"docs/readme.md": "hello"})
+	writeFiles(t, outside, map[string]string{"secret.txt": "password"})
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(newTreeServer(root, options{}))
+	defer srv.Close()
+
+	cases := []struct {
+		query  url.Values
+		status int
+	}{
+		{url.Values{"path": {".."}}, http.StatusForbidden},
+		{url.Values{"path": {"docs/../../"}}, http.StatusForbidden},
+		{url.Values{"path": {"/../etc"}}, http.StatusForbidden},
+		{url.Values{"path": {"escape"}}, http.StatusForbidden},
+		{url.Values{"path": {"missing"}}, http.StatusNotFound},
+		{url.Values{"format": {"xml"}}, http.StatusBadRequest},
+		{url.Values{"path": {"/docs/"}}, http.StatusOK},
+	}
+	for _, c := range cases {
+		resp, body := getTree(t, srv, c.query, nil)
+		if resp.StatusCode != c.status {
+			t.Errorf("%v: expected status %d, got %d", c.query, c.status, resp.StatusCode)
+		}
+		if strings.Contains(body, "secret") {
+			t.Errorf("%v: response leaks files outside the root:\n%s", c.query, body)
+		}
+	}
+}
+
+func TestServeLinksOutside(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	writeFiles(t, root, map[string]string{"pub/readme.md": "hello"})
+	writeFiles(t, outside, map[string]string{"inner/passwd.txt": "password", "secret.txt": "password"})
+	if err := os.Symlink(outside, filepath.Join(root, "pub", "esc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "pub", "copy.txt")); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := hashFile(os.DirFS(outside), "secret.txt", checksumSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := options{printFiles: true, follow: true, checksum: checksumSHA256}
+	srv := httptest.NewServer(newTreeServer(root, opts))
+	defer srv.Close()
+	for _, format := range []string{"text", "json"} {
+		resp, body := getTree(t, srv, url.Values{"path": {"pub"}, "format": {format}}, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d: %s", format, resp.StatusCode, body)
+		}
+		if strings.Contains(body, "passwd") || strings.Contains(body, sum[:16]) {
+			t.Errorf("%s: response leaks files outside the root:\n%s", format, body)
+		}
+		if !strings.Contains(body, "esc") {
+			t.Errorf("%s: expected the link itself to be listed:\n%s", format, body)
+		}
+	}
+}
+
+func TestServeConditional(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.txt": "a"})
+	srv := httptest.NewServer(newTreeServer(root, options{printFiles: true}))
+	defer srv.Close()
+
+	resp, _ := getTree(t, srv, nil, nil)
+	etag, modified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if etag == "" || modified == "" {
+		t.Fatalf("missing validators: ETag %q, Last-Modified %q", etag, modified)
+	}
+
+	resp, body := getTree(t, srv, nil, http.Header{"If-None-Match": {etag}})
+	if resp.StatusCode != http.StatusNotModified || body != "" {
+		t.Errorf("If-None-Match: expected 304 with no body, got %d %q", resp.StatusCode, body)
+	}
+	resp, _ = getTree(t, srv, nil, http.Header{"If-Modified-Since": {modified}})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("If-Modified-Since: expected 304, got %d", resp.StatusCode)
+	}
+
+	writeFiles(t, root, map[string]string{"b.txt": "b"})
+	resp, body = getTree(t, srv, nil, http.Header{"If-None-Match": {etag}})
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "b.txt") {
+		t.Errorf("changed tree: expected 200 with new entry, got %d %q", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type totals struct {
+	size  int64
+	dirs  int
+	files int
+}
+
+// sumTotals folds the totals of every subdirectory into its parent. Before
+// it runs each node only knows about the files directly inside it.
+func sumTotals(dir *node) {
+	for _, child := range dir.children {
+		if !child.info.IsDir() {
+			continue
+		}
+		sumTotals(child)
+		dir.total.size += child.total.size
+		dir.total.dirs += child.total.dirs + 1
+		dir.total.files += child.total.files
+	}
+}
+
+func pruneDepth(dir *node, depth int) {
+	if depth == 0 {
+		dir.children = nil
+		return
+	}
+	for _, child := range dir.children {
+		pruneDepth(child, depth-1)
+	}
+}
+
+// dropFiles removes the files that were only read to be counted.
+func dropFiles(dir *node) {
+	dirs := dir.children[:0]
+	for _, child := range dir.children {
+		if child.info.IsDir() {
+			dropFiles(child)
+			dirs = append(dirs, child)
+		}
+	}
+	dir.children = dirs
+}
+
+func (t totals) format(opts options) string {
+	return formatSize(t.size, opts.human) + ", " + t.counts(opts.printFiles)
+}
+
+func (t totals) counts(withFiles bool) string {
+	result := plural(t.dirs, "directory", "directories")
+	if withFiles {
+		result += ", " + plural(t.files, "file", "files")
+	}
+	return result
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return strconv.Itoa(n) + " " + many
+}
+
+var sizeUnits = []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+func formatSize(size int64, human bool) string {
+	if size <= 0 {
+		return "empty"
+	}
+	if !human || size < 1024 {
+		return strconv.FormatInt(size, 10) + "b"
+	}
+	value := float64(size) / 1024
+	unit := 0
+	for value >= 1024 && unit < len(sizeUnits)-1 {
+		value /= 1024
+		unit++
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + sizeUnits[unit]
+}
+
+// countListed counts the entries actually shown, which is less than the
+// subtree totals when --du walked below the depth limit.
+func countListed(dir *node) (t totals) {
+	for _, child := range dir.children {
+		if child.info.IsDir() {
+			sub := countListed(child)
+			t.dirs += sub.dirs + 1
+			t.files += sub.files
+		} else {
+			t.files++
+		}
+	}
+	return
+}
+
+func printReport(out io.Writer, root *node, opts options) error {
+	_, err := fmt.Fprintf(out, "\n%s\n", countListed(root).counts(opts.printFiles))
+	return err
+}
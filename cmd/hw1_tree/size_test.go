@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFormatSize(t *testing.T) {
+	cases := []struct {
+		size     int64
+		human    bool
+		expected string
+	}{
+		{0, false, "empty"},
+		{0, true, "empty"},
+		{19, true, "19b"},
+		{70372, false, "70372b"},
+		{70372, true, "68.7KiB"},
+		{5 << 20, true, "5.0MiB"},
+		{3 << 40, true, "3.0TiB"},
+	}
+	for _, c := range cases {
+		if got := formatSize(c.size, c.human); got != c.expected {
+			t.Errorf("formatSize(%d, %v) = %q, expected %q", c.size, c.human, got, c.expected)
+		}
+	}
+}
+
+const testDuResult = `├───project (70391b, 0 directories)
+├───static (281583b, 7 directories)
+│	├───a_lorem (140744b, 1 directory)
+│	│	└───ipsum (70372b, 0 directories)
+│	├───css (28b, 0 directories)
+│	├───html (57b, 0 directories)
+│	├───js (10b, 0 directories)
+│	└───z_lorem (140744b, 1 directory)
+│		└───ipsum (70372b, 0 directories)
+└───zline (140744b, 2 directories)
+	└───lorem (140744b, 1 directory)
+		└───ipsum (70372b, 0 directories)
+
+12 directories
+`
+
+const testDuHumanResult = `├───project (68.7KiB, 0 directories, 2 files)
+├───static (275.0KiB, 7 directories, 10 files)
+├───zline (137.4KiB, 2 directories, 4 files)
+└───zzfile.txt (empty)
+
+3 directories, 1 file
+`
+
+func TestTreeDu(t *testing.T) {
+	cases := []struct {
+		opts     options
+		expected string
+	}{
+		{options{du: true, report: true}, testDuResult},
+		{options{printFiles: true, du: true, human: true, report: true, maxDepth: 1}, testDuHumanResult},
+	}
+	for _, c := range cases {
+		out := new(bytes.Buffer)
+		if err := renderTree(out, "testdata", c.opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.String() != c.expected {
+			t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out.String(), c.expected)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"go-webservices/cmd/hw1_tree/tree"
+)
+
+const (
+	statusSame    = ' '
+	statusAdded   = '+'
+	statusRemoved = '-'
+	statusChanged = '~'
+)
+
+var errTreesDiffer = errors.New("trees differ")
+
+// snapshotDocument walks path with files included. Unreadable directories are
+// recorded in the document and returned as walkErrors alongside it.
+func snapshotDocument(path string, opts options) (*document, error) {
+	src, err := openSource(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.close()
+
+	opts.printFiles = true
+	root, walkErr := readTree(src, opts)
+	if root == nil {
+		return nil, walkErr
+	}
+	if opts.checksum != "" {
+		if err := hashTree(src, root, opts, allFiles); err != nil {
+			return nil, err
+		}
+	}
+	return newDocument(path, root, opts), walkErr
+}
+
+// checksumOf returns the algorithm the files in doc were hashed with.
+func checksumOf(doc *document) string {
+	if doc.Checksum != "" {
+		return doc.Checksum
+	}
+	for _, child := range doc.Children {
+		if algo := checksumOf(child); algo != "" {
+			return algo
+		}
+	}
+	return ""
+}
+
+func saveSnapshot(path, file string, opts options) error {
+	doc, walkErr := snapshotDocument(path, opts)
+	if doc == nil {
+		return walkErr
+	}
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return walkErr
+}
+
+func loadSnapshot(file string) (*document, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	doc := &document{}
+	if err := json.NewDecoder(in).Decode(doc); err != nil {
+		return nil, fmt.Errorf("%s: not a snapshot: %v", file, err)
+	}
+	return doc, nil
+}
+
+// loadTree reads a snapshot file or walks a directory or archive, hashing its
+// files with checksum if set, so they compare to the other side.
+func loadTree(path string, opts options, checksum string) (*document, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() && !isArchive(path) {
+		return loadSnapshot(path)
+	}
+	if checksum != "" {
+		opts.checksum = checksum
+	}
+	return snapshotDocument(path, opts)
+}
+
+type diffEntry struct {
+	name          string
+	status        byte
+	before, after *document
+	children      []*diffEntry
+}
+
+func (d *diffEntry) isDir() bool {
+	if d.after != nil {
+		return d.after.Type == typeDir
+	}
+	return d.before.Type == typeDir
+}
+
+// changed compares files by content when both sides have hashes and by size
+// and mtime otherwise. Directories only change by turning into something else,
+// changes inside them are reported on their children.
+func changed(before, after *document) bool {
+	if before.Type != after.Type || before.Target != after.Target {
+		return true
+	}
+	switch {
+	case before.Type == typeDir:
+		return false
+	case before.Sum != "" && before.Checksum == after.Checksum && after.Sum != "":
+		return before.Size != after.Size || before.Sum != after.Sum
+	default:
+		return before.Size != after.Size || !before.ModTime.Equal(after.ModTime)
+	}
+}
+
+func diffDocuments(name string, before, after *document) *diffEntry {
+	entry := &diffEntry{name: name, status: statusSame, before: before, after: after}
+	switch {
+	case before == nil:
+		entry.status = statusAdded
+	case after == nil:
+		entry.status = statusRemoved
+	case changed(before, after):
+		entry.status = statusChanged
+	}
+
+	befores := map[string]*document{}
+	afters := map[string]*document{}
+	var names []string
+	if before != nil {
+		for _, c := range before.Children {
+			befores[c.Name] = c
+			names = append(names, c.Name)
+		}
+	}
+	if after != nil {
+		for _, c := range after.Children {
+			if _, ok := befores[c.Name]; !ok {
+				names = append(names, c.Name)
+			}
+			afters[c.Name] = c
+		}
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		entry.children = append(entry.children, diffDocuments(n, befores[n], afters[n]))
+	}
+	return entry
+}
+
+func (d *diffEntry) details(opts options) string {
+	if d.isDir() {
+		return ""
+	}
+	switch {
+	case d.status == statusChanged && d.before.Size != d.after.Size:
+		return " (" + formatSize(d.before.Size, opts.human) + " -> " + formatSize(d.after.Size, opts.human) + ")"
+	case d.status == statusChanged:
+		return " (" + formatSize(d.after.Size, opts.human) + ", modified)"
+	case d.after != nil:
+		return " (" + formatSize(d.after.Size, opts.human) + ")"
+	default:
+		return " (" + formatSize(d.before.Size, opts.human) + ")"
+	}
+}
+
+type diffCounts map[byte]int
+
+func printDiff(out io.Writer, dir *diffEntry, parent tree.Position, opts options, counts diffCounts) error {
+	for i, entry := range dir.children {
+		pos := parent.Child(entry.name, i == len(dir.children)-1)
+		marker := ""
+		if entry.status != statusSame {
+			marker = string(entry.status) + " "
+			counts[entry.status]++
+		}
+		_, err := fmt.Fprintf(out, "%s%s%s%s%s\n", pos.Prefix, pos.Header(), marker, entry.name, entry.details(opts))
+		if err != nil {
+			return err
+		}
+		if err := printDiff(out, entry, pos, opts, counts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffTrees prints the tree of b marked against a and returns errTreesDiffer
+// if anything was added, removed or changed.
+func diffTrees(out io.Writer, a, b string, opts options) error {
+	before, beforeErr := loadTree(a, opts, "")
+	if before == nil {
+		return beforeErr
+	}
+	after, afterErr := loadTree(b, opts, checksumOf(before))
+	if after == nil {
+		return afterErr
+	}
+
+	counts := diffCounts{}
+	if err := printDiff(out, diffDocuments("", before, after), tree.Position{Lines: linesFor(opts)}, opts, counts); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "\n%d added, %d removed, %d changed\n",
+		counts[statusAdded], counts[statusRemoved], counts[statusChanged])
+	switch {
+	case err != nil:
+		return err
+	case len(counts) > 0:
+		return errTreesDiffer
+	case beforeErr != nil:
+		return beforeErr
+	default:
+		return afterErr
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+const testDiffResult = `├───+ VERSION (6b)
+├───bin
+│	├───~ app (3b -> 4b)
+│	├───config.ini (empty)
+│	└───~ lib.so (3b, modified)
+└───- docs
+	└───- readme.md (4b)
+
+1 added, 2 removed, 2 changed
+`
+
+func TestTreeDiff(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "release")
+	writeFiles(t, root, map[string]string{
+		"bin/app":        "v1\n",
+		"bin/lib.so":     "abc",
+		"bin/config.ini": "",
+		"docs/readme.md": "read",
+	})
+	snapshot := filepath.Join(dir, "before.json")
+	if err := saveSnapshot(root, snapshot, options{checksum: checksumSHA256}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := new(bytes.Buffer)
+	if err := diffTrees(out, snapshot, root, options{}); err != nil {
+		t.Fatalf("expected no differences, got %v\n%s", err, out.String())
+	}
+
+	if err := os.RemoveAll(filepath.Join(root, "docs")); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, root, map[string]string{
+		"bin/app":    "v1.1",
+		"bin/lib.so": "xyz",
+		"VERSION":    "1.1.0\n",
+	})
+	// only bin/lib.so keeps size and gets its old mtime back, hashes still
+	// have to catch the change
+	info, err := os.Stat(filepath.Join(root, "bin", "config.ini"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(root, "bin", "lib.so"), info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	err = diffTrees(out, snapshot, root, options{})
+	if err != errTreesDiffer {
+		t.Errorf("expected errTreesDiffer, got %v", err)
+	}
+	if out.String() != testDiffResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out.String(), testDiffResult)
+	}
+
+	after := filepath.Join(dir, "after.json")
+	if err := saveSnapshot(root, after, options{checksum: checksumSHA256}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out.Reset()
+	if err := diffTrees(out, snapshot, after, options{}); err != errTreesDiffer {
+		t.Errorf("expected errTreesDiffer, got %v", err)
+	}
+	if out.String() != testDiffResult {
+		t.Errorf("snapshot to snapshot results not match\nGot:\n%v\nExpected:\n%v", out.String(), testDiffResult)
+	}
+}
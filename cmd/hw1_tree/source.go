@@ -0,0 +1,88 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-webservices/cmd/hw1_tree/tree"
+)
+
+// source is the filesystem a tree is read from. dir is only set for trees on
+// disk: fs.FS has no notion of symlinks, so those are resolved through the os
+// package. name is the root as given by the user and prefixes error paths.
+type source struct {
+	fsys   fs.FS
+	dir    string
+	name   string
+	closer io.Closer
+}
+
+func isArchive(path string) bool {
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(strings.ToLower(path), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// openSource opens a directory or a zip, tar or tar.gz archive, which are
+// listed as if they were extracted.
+func openSource(path string) (source, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return source{}, err
+	}
+	if info.IsDir() {
+		return source{fsys: os.DirFS(path), dir: path, name: path}, nil
+	}
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return source{}, err
+		}
+		return source{fsys: r, name: path, closer: r}, nil
+	case isArchive(path):
+		fsys, err := readTar(path, !strings.HasSuffix(lower, ".tar"))
+		if err != nil {
+			return source{}, err
+		}
+		return source{fsys: fsys, name: path}, nil
+	}
+	return source{}, fmt.Errorf("%s: not a directory or a supported archive", path)
+}
+
+func (s source) close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+func (s source) treeSource() tree.Source {
+	return tree.Source{FS: s.fsys, Dir: s.dir, Name: s.name}
+}
+
+func (s source) pathError(err error) error {
+	return s.treeSource().PathError(err)
+}
+
+func (s source) resolveLinks(name string, list []os.FileInfo) []os.FileInfo {
+	return s.treeSource().ResolveLinks(name, list)
+}
+
+// lstat describes a single entry without following a final link where the
+// source has links at all.
+func (s source) lstat(name string) (os.FileInfo, error) {
+	if s.dir == "" {
+		return fs.Stat(s.fsys, name)
+	}
+	return os.Lstat(filepath.Join(s.dir, filepath.FromSlash(name)))
+}
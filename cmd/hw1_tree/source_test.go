@@ -0,0 +1,168 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//go:embed testdata
+var testdataFS embed.FS
+
+// writeArchive packs testdata the way tar and zip tools usually do: a single
+// top-level prefix, with directory entries only where the tool emits them.
+func writeArchive(t *testing.T, name string) string {
+	path := filepath.Join(t.TempDir(), name)
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var add func(name string, info os.FileInfo, data []byte) error
+	var finish func() error
+	switch filepath.Ext(name) {
+	case ".zip":
+		zw := zip.NewWriter(file)
+		add = func(name string, info os.FileInfo, data []byte) error {
+			if info.IsDir() {
+				return nil
+			}
+			w, err := zw.Create(name)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		}
+		finish = zw.Close
+	default:
+		var w io.Writer = file
+		var gz *gzip.Writer
+		if ext := filepath.Ext(name); ext == ".gz" || ext == ".tgz" {
+			gz = gzip.NewWriter(file)
+			w = gz
+		}
+		tw := tar.NewWriter(w)
+		add = func(name string, info os.FileInfo, data []byte) error {
+			hdr, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			hdr.Name = "./" + name
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			_, err = tw.Write(data)
+			return err
+		}
+		finish = func() error {
+			if err := tw.Close(); err != nil {
+				return err
+			}
+			if gz != nil {
+				return gz.Close()
+			}
+			return nil
+		}
+	}
+
+	err = filepath.Walk("testdata", func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == "testdata" {
+			return err
+		}
+		rel, _ := filepath.Rel("testdata", path)
+		var data []byte
+		if !info.IsDir() {
+			if data, err = ioutil.ReadFile(path); err != nil {
+				return err
+			}
+		}
+		return add(filepath.ToSlash(rel), info, data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := finish(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTreeArchives(t *testing.T) {
+	for _, name := range []string{"release.zip", "release.tar", "release.tar.gz"} {
+		archive := writeArchive(t, name)
+		for _, printFiles := range []bool{true, false} {
+			expected := testDirResult
+			if printFiles {
+				expected = testFullResult
+			}
+			out := new(bytes.Buffer)
+			if err := renderTree(out, archive, options{printFiles: printFiles}); err != nil {
+				t.Errorf("%s: unexpected error: %v", name, err)
+			}
+			if out.String() != expected {
+				t.Errorf("%s: results not match\nGot:\n%v\nExpected:\n%v", name, out.String(), expected)
+			}
+		}
+	}
+}
+
+func TestTreeEmbed(t *testing.T) {
+	sub, err := fs.Sub(testdataFS, "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(bytes.Buffer)
+	if err := renderFS(out, "testdata", sub, options{printFiles: true}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if out.String() != testFullResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out.String(), testFullResult)
+	}
+}
+
+func TestTarFSMissing(t *testing.T) {
+	archive := writeArchive(t, "release.tgz")
+	src, err := openSource(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = src.fsys.Open("project/missing.txt")
+	if !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+	data, err := fs.ReadFile(src.fsys, "project/file.txt")
+	if err != nil || len(data) != 19 {
+		t.Errorf("unexpected read of project/file.txt: %d bytes, %v", len(data), err)
+	}
+}
+
+func TestTarChecksum(t *testing.T) {
+	for _, name := range []string{"release.tar", "release.tar.gz"} {
+		archive := writeArchive(t, name)
+		for _, opts := range []options{
+			{printFiles: true, checksum: checksumMD5, workers: 3},
+			{printFiles: true, dupes: true, human: true},
+		} {
+			expected, got := new(bytes.Buffer), new(bytes.Buffer)
+			if err := renderTree(expected, "testdata", opts); err != nil {
+				t.Fatal(err)
+			}
+			if err := renderTree(got, archive, opts); err != nil {
+				t.Errorf("%s: unexpected error: %v", name, err)
+			}
+			if got.String() != expected.String() {
+				t.Errorf("%s: results not match\nGot:\n%v\nExpected:\n%v", name, got.String(), expected.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+
+	"go-webservices/cmd/hw1_tree/tree"
+)
+
+// streamBatch is how many entries of a directory are read, and in sorted
+// mode held in memory, at a time.
+var streamBatch = 1 << 14
+
+var openDir = func(fsys fs.FS, name string) (fs.ReadDirFile, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	dir, ok := file.(fs.ReadDirFile)
+	if !ok {
+		file.Close()
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return dir, nil
+}
+
+// dirStream yields the entries of one directory until io.EOF.
+type dirStream interface {
+	next() (os.FileInfo, error)
+	close() error
+}
+
+func readDirError(name string, err error) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+	return &fs.PathError{Op: "readdir", Path: name, Err: err}
+}
+
+// unsortedStream passes entries on in the order the directory returns them.
+type unsortedStream struct {
+	src   source
+	name  string
+	dir   fs.ReadDirFile
+	batch []fs.DirEntry
+}
+
+func (s *unsortedStream) next() (os.FileInfo, error) {
+	for {
+		for len(s.batch) == 0 {
+			var err error
+			if s.batch, err = s.dir.ReadDir(streamBatch); err == io.EOF {
+				return nil, io.EOF
+			} else if err != nil {
+				return nil, readDirError(s.name, err)
+			}
+		}
+		entry := s.batch[0]
+		s.batch = s.batch[1:]
+		info, err := entryInfo(s.src, s.name, entry)
+		if errors.Is(err, fs.ErrNotExist) {
+			// removed since it was listed
+			continue
+		}
+		return info, err
+	}
+}
+
+func (s *unsortedStream) close() error {
+	return s.dir.Close()
+}
+
+func entryInfo(src source, dir string, entry fs.DirEntry) (os.FileInfo, error) {
+	info, err := entry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return src.resolveLinks(dir, []os.FileInfo{info})[0], nil
+}
+
+// memoryStream serves a directory that fit into a single batch.
+type memoryStream struct {
+	src     source
+	name    string
+	entries []fs.DirEntry
+}
+
+func (s *memoryStream) next() (os.FileInfo, error) {
+	for len(s.entries) > 0 {
+		entry := s.entries[0]
+		s.entries = s.entries[1:]
+		info, err := entryInfo(s.src, s.name, entry)
+		if errors.Is(err, fs.ErrNotExist) {
+			// removed since it was listed
+			continue
+		}
+		return info, err
+	}
+	return nil, io.EOF
+}
+
+func (s *memoryStream) close() error {
+	return nil
+}
+
+// sortedRun is one sorted batch of names spilled to a temporary file, one quoted
+// name per line.
+type sortedRun struct {
+	file *os.File
+	r    *bufio.Reader
+	head string
+}
+
+func writeRun(batch []fs.DirEntry) (*sortedRun, error) {
+	sort.Slice(batch, func(i, j int) bool { return batch[i].Name() < batch[j].Name() })
+	file, err := os.CreateTemp("", "tree-*.run")
+	if err != nil {
+		return nil, err
+	}
+	r := &sortedRun{file: file}
+	w := bufio.NewWriter(file)
+	for _, entry := range batch {
+		w.WriteString(strconv.Quote(entry.Name()))
+		w.WriteByte('\n')
+	}
+	if err = w.Flush(); err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		r.close()
+		return nil, err
+	}
+	r.r = bufio.NewReader(file)
+	return r, nil
+}
+
+// advance loads the next name into head and reports false at the end.
+func (r *sortedRun) advance() (bool, error) {
+	line, err := r.r.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	r.head, err = strconv.Unquote(line[:len(line)-1])
+	return err == nil, err
+}
+
+func (r *sortedRun) close() error {
+	err := r.file.Close()
+	if rmErr := os.Remove(r.file.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+type runHeap []*sortedRun
+
+func (h runHeap) Len() int            { return len(h) }
+func (h runHeap) Less(i, j int) bool  { return h[i].head < h[j].head }
+func (h runHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*sortedRun)) }
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// mergeStream merges the runs of a directory too large for one batch. Only
+// names are spilled, the rest is looked up again as each entry comes up.
+type mergeStream struct {
+	src  source
+	name string
+	runs []*sortedRun
+	heap runHeap
+}
+
+func (s *mergeStream) next() (os.FileInfo, error) {
+	for len(s.heap) > 0 {
+		r := s.heap[0]
+		name := r.head
+		ok, err := r.advance()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			heap.Fix(&s.heap, 0)
+		} else {
+			heap.Pop(&s.heap)
+		}
+		info, err := s.src.lstat(path.Join(s.name, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			// removed since it was listed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return s.src.resolveLinks(s.name, []os.FileInfo{info})[0], nil
+	}
+	return nil, io.EOF
+}
+
+func (s *mergeStream) spill(batch []fs.DirEntry) error {
+	r, err := writeRun(batch)
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, r)
+	return nil
+}
+
+func (s *mergeStream) close() (err error) {
+	for _, r := range s.runs {
+		if closeErr := r.close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// sortedStream reads name in batches. A directory that fits into one batch
+// is sorted in memory, larger ones are sorted batch by batch into temporary
+// files that are merged while listing.
+func sortedStream(src source, name string, dir fs.ReadDirFile) (dirStream, error) {
+	defer dir.Close()
+	first, err := dir.ReadDir(streamBatch)
+	if err != nil && err != io.EOF {
+		return nil, readDirError(name, err)
+	}
+	merge := &mergeStream{src: src, name: name}
+	for {
+		batch, err := dir.ReadDir(streamBatch)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			merge.close()
+			return nil, readDirError(name, err)
+		}
+		if merge.runs == nil {
+			if err := merge.spill(first); err != nil {
+				merge.close()
+				return nil, err
+			}
+			first = nil
+		}
+		if err := merge.spill(batch); err != nil {
+			merge.close()
+			return nil, err
+		}
+	}
+	if merge.runs == nil {
+		sort.Slice(first, func(i, j int) bool { return first[i].Name() < first[j].Name() })
+		return &memoryStream{src: src, name: name, entries: first}, nil
+	}
+	for _, r := range merge.runs {
+		ok, err := r.advance()
+		if err != nil {
+			merge.close()
+			return nil, err
+		}
+		if ok {
+			merge.heap = append(merge.heap, r)
+		}
+	}
+	heap.Init(&merge.heap)
+	return merge, nil
+}
+
+// streamer prints a tree while reading it, holding one open directory and
+// one pending entry per level instead of the whole tree.
+type streamer struct {
+	out   io.Writer
+	src   source
+	opts  options
+	total totals
+	errs  walkErrors
+}
+
+func (s *streamer) open(name string) (dirStream, error) {
+	dir, err := openDir(s.src.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if s.opts.unsorted {
+		return &unsortedStream{src: s.src, name: name, dir: dir}, nil
+	}
+	return sortedStream(s.src, name, dir)
+}
+
+func (s *streamer) fail(err error) error {
+	s.errs = append(s.errs, s.src.pathError(err))
+	if s.opts.strict {
+		return s.errs[0]
+	}
+	return nil
+}
+
+// listDir prints the entries of dir, each one as soon as the next is known,
+// since the last one is drawn differently.
+func (s *streamer) listDir(dir dirStream, name string, parent tree.Position) error {
+	defer dir.close()
+	var pending os.FileInfo
+	for {
+		info, err := dir.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if err := s.fail(err); err != nil {
+				return err
+			}
+			break
+		}
+		if !s.opts.printFiles && !info.IsDir() {
+			continue
+		}
+		if !s.opts.treeOptions().Match(info) {
+			continue
+		}
+		if pending != nil {
+			if err := s.printEntry(pending, name, parent, false); err != nil {
+				return err
+			}
+		}
+		pending = info
+	}
+	if pending == nil {
+		return nil
+	}
+	return s.printEntry(pending, name, parent, true)
+}
+
+func (s *streamer) printEntry(info os.FileInfo, dir string, parent tree.Position, last bool) error {
+	pos := parent.Child(info.Name(), last)
+	entry := &node{info: info}
+	var sub dirStream
+	_, isLink := info.(*tree.Link)
+	if info.IsDir() {
+		s.total.dirs++
+		if !isLink && (s.opts.maxDepth == 0 || pos.Depth < s.opts.maxDepth) {
+			if sub, entry.err = s.open(pos.Path); entry.err != nil {
+				if err := s.fail(entry.err); err != nil {
+					return err
+				}
+			}
+		}
+	} else {
+		s.total.files++
+	}
+
+	_, err := fmt.Fprintf(s.out, "%s%s%s%s\n", pos.Prefix, pos.Header(), displayName(entry), entryDetails(entry, s.opts))
+	if err != nil {
+		if sub != nil {
+			sub.close()
+		}
+		return err
+	}
+	if sub == nil {
+		return nil
+	}
+	return s.listDir(sub, pos.Path, pos)
+}
+
+// streamTree is the --stream counterpart of readTree and the text renderer.
+func streamTree(out io.Writer, src source, opts options) error {
+	s := &streamer{out: out, src: src, opts: opts}
+	root, err := s.open(".")
+	if err != nil {
+		return src.pathError(err)
+	}
+	if err := s.listDir(root, ".", tree.Position{Lines: linesFor(opts)}); err != nil {
+		return err
+	}
+	if opts.report {
+		if _, err := fmt.Fprintf(out, "\n%s\n", s.total.counts(opts.printFiles)); err != nil {
+			return err
+		}
+	}
+	if len(s.errs) > 0 {
+		return s.errs
+	}
+	return nil
+}
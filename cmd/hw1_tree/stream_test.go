@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStreamMatchesTree(t *testing.T) {
+	generated := t.TempDir()
+	makeTree(t, generated, 2, 7)
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	cases := []options{
+		{printFiles: true},
+		{},
+		{printFiles: true, maxDepth: 2, human: true},
+		{printFiles: true, exclude: []string{"*.png"}, include: []string{"*.txt"}},
+		{printFiles: true, report: true, charset: charsetASCII},
+	}
+	for _, batch := range []int{streamBatch, 3} {
+		orig := streamBatch
+		streamBatch = batch
+		for _, path := range []string{"testdata", generated} {
+			for _, opts := range cases {
+				expected, streamed := new(bytes.Buffer), new(bytes.Buffer)
+				if err := renderTree(expected, path, opts); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				opts.stream = true
+				if err := renderTree(streamed, path, opts); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if streamed.String() != expected.String() {
+					t.Errorf("batch %d, %s, %+v: results not match\nGot:\n%v\nExpected:\n%v",
+						batch, path, opts, streamed.String(), expected.String())
+				}
+			}
+		}
+		streamBatch = orig
+	}
+
+	if left, _ := ioutil.ReadDir(tmp); len(left) > 0 {
+		t.Errorf("temporary runs were not removed: %v", left[0].Name())
+	}
+}
+
+func TestStreamUnsorted(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, 1, 20)
+
+	sortedLines := func(opts options) []string {
+		out := new(bytes.Buffer)
+		if err := renderTree(out, root, opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		lines := strings.Split(out.String(), "\n")
+		for i, line := range lines {
+			lines[i] = strings.NewReplacer("├───", "", "└───", "", "│", "").Replace(line)
+		}
+		sort.Strings(lines)
+		return lines
+	}
+	expected := sortedLines(options{printFiles: true, maxDepth: 1})
+	got := sortedLines(options{printFiles: true, maxDepth: 1, stream: true, unsorted: true})
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unsorted stream lists different entries\nGot:\n%v\nExpected:\n%v", got, expected)
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	orig := openDir
+	openDir = func(fsys fs.FS, name string) (fs.ReadDirFile, error) {
+		if name == "project" || name == "static/z_lorem" {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: errDenied}
+		}
+		return orig(fsys, name)
+	}
+	defer func() { openDir = orig }()
+
+	out := new(bytes.Buffer)
+	err := renderTree(out, "testdata", options{stream: true, maxDepth: 3})
+	errs, ok := err.(walkErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected two walk errors, got %v", err)
+	}
+	if !strings.HasPrefix(out.String(), testErrorResult[:strings.Index(testErrorResult, "\n")+1]) ||
+		!strings.Contains(out.String(), "z_lorem [error opening dir]\n") {
+		t.Errorf("errors not marked inline:\n%v", out.String())
+	}
+
+	err = renderTree(new(bytes.Buffer), "testdata", options{stream: true, strict: true})
+	if _, ok := err.(walkErrors); ok || err == nil {
+		t.Errorf("expected the first error in strict mode, got %v", err)
+	}
+}
+
+// removingDir removes a file after each listing of the directory.
+type removingDir struct {
+	fs.ReadDirFile
+	remove string
+}
+
+func (d removingDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := d.ReadDirFile.ReadDir(n)
+	os.Remove(d.remove)
+	return entries, err
+}
+
+func TestStreamRemoved(t *testing.T) {
+	root := t.TempDir()
+	orig := openDir
+	openDir = func(fsys fs.FS, name string) (fs.ReadDirFile, error) {
+		dir, err := orig(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		return removingDir{dir, filepath.Join(root, "b.txt")}, nil
+	}
+	defer func() { openDir = orig }()
+
+	cases := []struct {
+		name     string
+		unsorted bool
+		batch    int
+	}{
+		{"unsorted", true, streamBatch},
+		{"sorted", false, streamBatch},
+		{"merged", false, 1},
+	}
+	for _, c := range cases {
+		writeFiles(t, root, map[string]string{"a.txt": "a", "b.txt": "b", "c.txt": "c"})
+		orig := streamBatch
+		streamBatch = c.batch
+		out := new(bytes.Buffer)
+		err := renderTree(out, root, options{printFiles: true, stream: true, unsorted: c.unsorted})
+		streamBatch = orig
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+		}
+		// the unsorted stream may list c.txt first
+		got := strings.Replace(out.String(), "├───c.txt (1b)\n└───a.txt", "├───a.txt (1b)\n└───c.txt", 1)
+		if got != "├───a.txt (1b)\n└───c.txt (1b)\n" {
+			t.Errorf("%s: expected a.txt and c.txt only, got:\n%v", c.name, out.String())
+		}
+	}
+}
+
+// peakHeap samples the live heap while f runs.
+func peakHeap(f func()) uint64 {
+	runtime.GC()
+	var peak uint64
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		var stats runtime.MemStats
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > atomic.LoadUint64(&peak) {
+				atomic.StoreUint64(&peak, stats.HeapAlloc)
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+	f()
+	close(done)
+	<-sampled
+	return atomic.LoadUint64(&peak)
+}
+
+// BenchmarkStreamHuge lists one flat directory in memory and streamed. The
+// peak-heap metric grows with the directory for the former only.
+func BenchmarkStreamHuge(b *testing.B) {
+	for _, size := range []int{10000, 50000} {
+		root := b.TempDir()
+		for i := 0; i < size; i++ {
+			if err := ioutil.WriteFile(filepath.Join(root, fmt.Sprintf("f%07d", i)), nil, 0644); err != nil {
+				b.Fatal(err)
+			}
+		}
+		orig := streamBatch
+		streamBatch = 1024
+		for _, mode := range []struct {
+			name string
+			opts options
+		}{
+			{"tree", options{printFiles: true}},
+			{"stream", options{printFiles: true, stream: true}},
+			{"unsorted", options{printFiles: true, stream: true, unsorted: true}},
+		} {
+			b.Run(fmt.Sprintf("%s/%d", mode.name, size), func(b *testing.B) {
+				var peak uint64
+				for i := 0; i < b.N; i++ {
+					p := peakHeap(func() {
+						if err := renderTree(ioutil.Discard, root, mode.opts); err != nil {
+							b.Fatal(err)
+						}
+					})
+					if p > peak {
+						peak = p
+					}
+				}
+				b.ReportMetric(float64(peak), "peak-heap-B")
+			})
+		}
+		streamBatch = orig
+		os.RemoveAll(root)
+	}
+}
@@ -0,0 +1,29 @@
+package tree
+
+import "strings"
+
+// Errors collects every directory that could not be read during a walk, in
+// the order they appear in the tree.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+func collectErrors(dir *Node, errs Errors) Errors {
+	if dir.Err != nil {
+		errs = append(errs, dir.Err)
+	}
+	for _, child := range dir.Children {
+		errs = collectErrors(child, errs)
+	}
+	return errs
+}
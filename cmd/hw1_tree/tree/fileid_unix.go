@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package tree
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+type fileID struct {
+	dev, ino uint64
+}
+
+func osFileKey(info fs.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
@@ -0,0 +1,189 @@
+package tree
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const gitignoreFile = ".gitignore"
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether info passes the Exclude and Include patterns. Like
+// GNU tree, Include only applies to files so matching files deep in the tree
+// stay reachable.
+func (o Options) Match(info fs.FileInfo) bool {
+	if matchAny(o.Exclude, info.Name()) {
+		return false
+	}
+	return info.IsDir() || len(o.Include) == 0 || matchAny(o.Include, info.Name())
+}
+
+// filterList drops entries not matched by the patterns and, with Gitignore,
+// everything the collected .gitignore rules ignore.
+func filterList(list []fs.FileInfo, dir string, opts Options, ignore *ignoreList) (result []fs.FileInfo) {
+	for _, f := range list {
+		if !opts.Match(f) {
+			continue
+		}
+		if opts.Gitignore {
+			if f.IsDir() && f.Name() == ".git" {
+				continue
+			}
+			if ignore.ignored(path.Join(dir, f.Name()), f.IsDir()) {
+				continue
+			}
+		}
+		result = append(result, f)
+	}
+	return
+}
+
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreList holds the rules of one .gitignore file and links to the file of
+// the enclosing directory, so nested files take precedence over outer ones.
+type ignoreList struct {
+	base   string
+	rules  []ignoreRule
+	parent *ignoreList
+}
+
+// load returns the list for dir: a new one on top of l if dir has a
+// .gitignore, l itself otherwise. l may be nil.
+func (l *ignoreList) load(fsys fs.FS, dir string) (*ignoreList, error) {
+	file, err := fsys.Open(path.Join(dir, gitignoreFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return l, err
+	}
+	defer file.Close()
+
+	list := &ignoreList{base: dir, parent: l}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			list.rules = append(list.rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return l, err
+	}
+	return list, nil
+}
+
+func (l *ignoreList) ignored(name string, isDir bool) bool {
+	for ; l != nil; l = l.parent {
+		rel := name
+		if l.base != "." {
+			if !strings.HasPrefix(name, l.base+"/") {
+				continue
+			}
+			rel = name[len(l.base)+1:]
+		}
+		// the last matching rule of the innermost file wins
+		for i := len(l.rules) - 1; i >= 0; i-- {
+			rule := l.rules[i]
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.pattern.MatchString(rel) {
+				return !rule.negate
+			}
+		}
+	}
+	return false
+}
+
+func parseIgnoreRule(line string) (rule ignoreRule, ok bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+
+	// a slash anywhere but at the end anchors the pattern to the .gitignore
+	// directory, otherwise it matches at any level below it
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return rule, false
+	}
+	rule.pattern = re
+	return rule, true
+}
+
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
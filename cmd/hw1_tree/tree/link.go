@@ -0,0 +1,99 @@
+package tree
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Link describes a symlink as listed by ReadDir. It reports the size and
+// kind of its target, so directory links are kept without Files and followed
+// with FollowLinks, while Mode still carries fs.ModeSymlink.
+type Link struct {
+	fs.FileInfo
+	Target string
+	// Resolved describes the target, it is nil for a dangling link
+	Resolved fs.FileInfo
+}
+
+func (l *Link) IsDir() bool {
+	return l.Resolved != nil && l.Resolved.IsDir()
+}
+
+func (l *Link) Size() int64 {
+	if l.Resolved == nil {
+		return 0
+	}
+	return l.Resolved.Size()
+}
+
+func (l *Link) Dangling() bool {
+	return l.Resolved == nil
+}
+
+// Identified is implemented by the entries of sources that are not on disk
+// but still tell which ones are the same, like archives, so that links going
+// round in circles are detected there too.
+type Identified interface {
+	fs.FileInfo
+	FileID() uint64
+}
+
+// fileKey identifies the target of a link, or any other entry.
+func fileKey(info fs.FileInfo) (fileID, bool) {
+	if link, ok := info.(*Link); ok {
+		if link.Resolved == nil {
+			return fileID{}, false
+		}
+		info = link.Resolved
+	}
+	if id, ok := info.(Identified); ok {
+		return fileID{ino: id.FileID()}, true
+	}
+	return osFileKey(info)
+}
+
+// ResolveLinks replaces the symlinks in list, read from the directory at
+// path on disk, with a *Link.
+func ResolveLinks(path string, list []fs.FileInfo) []fs.FileInfo {
+	for i, f := range list {
+		if f.Mode()&fs.ModeSymlink == 0 {
+			continue
+		}
+		linkPath := filepath.Join(path, f.Name())
+		target, err := os.Readlink(linkPath)
+		if err != nil {
+			continue
+		}
+		link := &Link{FileInfo: f, Target: target}
+		if resolved, err := os.Stat(linkPath); err == nil {
+			link.Resolved = resolved
+		}
+		list[i] = link
+	}
+	return list
+}
+
+// visit is one directory on the path from the root to the one being walked.
+// Nodes are never modified, so goroutines can share a common prefix.
+type visit struct {
+	id     fileID
+	parent *visit
+}
+
+func (v *visit) contains(id fileID) bool {
+	for ; v != nil; v = v.parent {
+		if v.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *visit) push(info fs.FileInfo) *visit {
+	id, ok := fileKey(info)
+	if !ok {
+		return v
+	}
+	return &visit{id: id, parent: v}
+}
@@ -0,0 +1,90 @@
+package tree
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// Keys for Options.SortBy, an empty key sorts by name.
+const (
+	SortName    = "name"
+	SortVersion = "version"
+	SortSize    = "size"
+	SortMtime   = "mtime"
+)
+
+// lessFunc orders entries by the sort key, falling back to the name so the
+// output stays stable. Like GNU tree, DirsFirst is not affected by Reverse.
+func lessFunc(opts Options) func(a, b fs.FileInfo) bool {
+	key := func(a, b fs.FileInfo) bool {
+		return a.Name() < b.Name()
+	}
+	switch opts.SortBy {
+	case SortVersion:
+		key = func(a, b fs.FileInfo) bool {
+			return naturalLess(a.Name(), b.Name())
+		}
+	case SortSize:
+		key = func(a, b fs.FileInfo) bool {
+			if a.Size() != b.Size() {
+				return a.Size() > b.Size()
+			}
+			return a.Name() < b.Name()
+		}
+	case SortMtime:
+		key = func(a, b fs.FileInfo) bool {
+			if !a.ModTime().Equal(b.ModTime()) {
+				return a.ModTime().Before(b.ModTime())
+			}
+			return a.Name() < b.Name()
+		}
+	}
+	return func(a, b fs.FileInfo) bool {
+		if opts.DirsFirst && a.IsDir() != b.IsDir() {
+			return a.IsDir()
+		}
+		if opts.Reverse {
+			return key(b, a)
+		}
+		return key(a, b)
+	}
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// naturalLess compares runs of digits by their numeric value, so "file9"
+// sorts before "file10". Names equal in value are ordered byte-wise.
+func naturalLess(a, b string) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if !isDigit(a[i]) || !isDigit(b[j]) {
+			if a[i] != b[j] {
+				return a[i] < b[j]
+			}
+			i++
+			j++
+			continue
+		}
+		si, sj := i, j
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+		na := strings.TrimLeft(a[si:i], "0")
+		nb := strings.TrimLeft(b[sj:j], "0")
+		if len(na) != len(nb) {
+			return len(na) < len(nb)
+		}
+		if na != nb {
+			return na < nb
+		}
+	}
+	if len(a)-i != len(b)-j {
+		return len(a)-i < len(b)-j
+	}
+	return a < b
+}
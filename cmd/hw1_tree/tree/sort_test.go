@@ -0,0 +1,81 @@
+package tree
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNaturalLess(t *testing.T) {
+	sorted := []string{"file", "file1", "file01.txt", "file1.txt", "file9", "file10", "file10a", "file10b", "v1.2.9", "v1.2.10", "v1.10.0"}
+	for i := range sorted {
+		for j := range sorted {
+			if got := naturalLess(sorted[i], sorted[j]); got != (i < j) {
+				t.Errorf("naturalLess(%q, %q) = %v", sorted[i], sorted[j], got)
+			}
+		}
+	}
+}
+
+func TestTreeSort(t *testing.T) {
+	root := t.TempDir()
+	base := time.Date(2021, 12, 26, 0, 0, 0, 0, time.UTC)
+	files := []struct {
+		name string
+		size int
+	}{
+		{"file10", 5},
+		{"file9", 20},
+		{"file1", 20},
+		{"dir2", -1},
+	}
+	for i, f := range files {
+		path := filepath.Join(root, f.name)
+		var err error
+		if f.size < 0 {
+			err = os.Mkdir(path, 0755)
+		} else {
+			err = ioutil.WriteFile(path, bytes.Repeat([]byte("x"), f.size), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		mtime := base.Add(time.Duration(i) * time.Hour)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{"name", Options{}, "dir2 file1 file10 file9"},
+		{"version", Options{SortBy: SortVersion}, "dir2 file1 file9 file10"},
+		{"version reversed", Options{SortBy: SortVersion, Reverse: true}, "file10 file9 file1 dir2"},
+		{"mtime", Options{SortBy: SortMtime}, "file10 file9 file1 dir2"},
+		{"dirs first", Options{SortBy: SortMtime, DirsFirst: true, Reverse: true}, "dir2 file1 file9 file10"},
+		{"size", Options{SortBy: SortSize, DirsFirst: true}, "dir2 file1 file9 file10"},
+	}
+	for _, c := range cases {
+		entries, err := ioutil.ReadDir(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.opts.Files = true
+		var names []byte
+		for _, e := range cleanupList(entries, c.opts) {
+			if len(names) > 0 {
+				names = append(names, ' ')
+			}
+			names = append(names, e.Name()...)
+		}
+		if string(names) != c.expected {
+			t.Errorf("[%s] got %q, expected %q", c.name, names, c.expected)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Package tree reads a directory tree into memory and walks it in the order
+// the tree command prints it.
+package tree
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Options select and order the entries of a tree. The zero value lists
+// directories only, sorted by name, without a depth limit.
+type Options struct {
+	// Files lists files as well as directories
+	Files bool
+	// MaxDepth stops descending below that many levels, 0 means no limit
+	MaxDepth int
+	// Exclude drops entries matching any of the patterns, Include keeps
+	// only files matching one of them; see filepath.Match
+	Exclude []string
+	Include []string
+	// Gitignore skips everything .gitignore files in the tree ignore
+	Gitignore bool
+	// FollowLinks descends into links to directories, except for links
+	// back to one of their ancestors
+	FollowLinks bool
+	// Strict stops at the first directory that cannot be read
+	Strict bool
+	// Workers reads directories concurrently, the order of the tree does
+	// not depend on it
+	Workers int
+	SortBy  string
+	// DirsFirst lists directories before files, regardless of Reverse
+	DirsFirst bool
+	Reverse   bool
+	// ReadDir replaces the function directories are listed with
+	ReadDir func(fsys fs.FS, name string) ([]fs.FileInfo, error)
+}
+
+// Node is one entry of a tree.
+type Node struct {
+	// Info is a *Link for symbolic links
+	Info     fs.FileInfo
+	Children []*Node
+	// Err is why a directory could not be read, it has no children then
+	Err error
+	// Recursive marks a directory link back to one of its own ancestors
+	Recursive bool
+}
+
+// Source is a file system to read a tree from. Dir is where FS lives on
+// disk, if anywhere, and is needed to resolve symbolic links. Name is the
+// root as the user knows it and prefixes the paths in errors.
+type Source struct {
+	FS   fs.FS
+	Dir  string
+	Name string
+}
+
+// DirSource reads the directory at root.
+func DirSource(root string) Source {
+	return Source{FS: os.DirFS(root), Dir: root, Name: root}
+}
+
+// PathError rewrites the path of err from one relative to FS to one the
+// user can recognise.
+func (s Source) PathError(err error) error {
+	var pe *fs.PathError
+	if s.Name == "" || !errors.As(err, &pe) {
+		return err
+	}
+	rooted := *pe
+	rooted.Path = filepath.Join(s.Name, filepath.FromSlash(pe.Path))
+	return &rooted
+}
+
+// ResolveLinks resolves the symlinks in list, read from the directory name
+// of FS, if FS is on disk.
+func (s Source) ResolveLinks(name string, list []fs.FileInfo) []fs.FileInfo {
+	if s.Dir == "" {
+		return list
+	}
+	return ResolveLinks(filepath.Join(s.Dir, filepath.FromSlash(name)), list)
+}
+
+// Read reads the whole tree. Directories that cannot be read are marked
+// on their nodes and returned together as Errors, unless opts.Strict is set
+// and the first of them is returned without a tree.
+func (s Source) Read(opts Options) (*Node, error) {
+	info, err := fs.Stat(s.FS, ".")
+	if err != nil {
+		return nil, s.PathError(err)
+	}
+	root := &Node{Info: info}
+	w := &walker{src: s, opts: opts}
+	if opts.Workers > 1 {
+		w.sem = make(chan struct{}, opts.Workers-1)
+	}
+	w.walkDir(root, ".", 1, nil, (*visit)(nil).push(info))
+	w.wg.Wait()
+	errs := collectErrors(root, nil)
+	if len(errs) > 0 && opts.Strict {
+		return nil, errs[0]
+	}
+	if len(errs) > 0 {
+		return root, errs
+	}
+	return root, nil
+}
+
+// Read reads the tree of the directory root.
+func Read(root string, opts Options) (*Node, error) {
+	return DirSource(root).Read(opts)
+}
+
+// Lines are the strings a tree is drawn with: Branch and Last lead the
+// entries of a directory, Pipe continues the line of an open directory.
+type Lines struct {
+	Branch, Last, Pipe string
+}
+
+var (
+	UTF8Lines  = &Lines{Branch: "├───", Last: "└───", Pipe: "│"}
+	ASCIILines = &Lines{Branch: "|--", Last: `\--`, Pipe: "|"}
+)
+
+// Position places a node in the printed tree. Depth is 1 for the entries of
+// the root, Last marks the last entry of a directory and Prefix holds the
+// lines drawn for the directories above it. Path is slash-separated and
+// relative to the root. Lines draw the tree, UTF8Lines when nil.
+type Position struct {
+	Depth  int
+	Last   bool
+	Prefix string
+	Path   string
+	Lines  *Lines
+}
+
+func (p Position) lines() *Lines {
+	if p.Lines == nil {
+		return UTF8Lines
+	}
+	return p.Lines
+}
+
+// Header is drawn between the prefix and the name of an entry.
+func (p Position) Header() string {
+	if p.Last {
+		return p.lines().Last
+	}
+	return p.lines().Branch
+}
+
+func (p Position) childPrefix() string {
+	if p.Depth == 0 {
+		return ""
+	}
+	if p.Last {
+		return p.Prefix + "\t"
+	}
+	return p.Prefix + p.lines().Pipe + "\t"
+}
+
+// Child is the position of the entry name below p, last if it is the last
+// one listed there.
+func (p Position) Child(name string, last bool) Position {
+	return Position{
+		Depth:  p.Depth + 1,
+		Last:   last,
+		Prefix: p.childPrefix(),
+		Path:   path.Join(p.Path, name),
+		Lines:  p.Lines,
+	}
+}
+
+// Visitor is called for every node below the root, parents before their
+// children. Returning fs.SkipDir for a directory skips its children, any
+// other error stops the walk.
+type Visitor func(n *Node, pos Position) error
+
+// Visit calls visit for the nodes below root in order.
+func Visit(root *Node, visit Visitor) error {
+	return visitChildren(root, Position{}, visit)
+}
+
+func visitChildren(dir *Node, parent Position, visit Visitor) error {
+	for i, child := range dir.Children {
+		pos := parent.Child(child.Info.Name(), i == len(dir.Children)-1)
+		err := visit(child, pos)
+		if err == fs.SkipDir {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := visitChildren(child, pos, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Walk reads the tree of s and visits it. Unreadable directories are
+// reported as by Read once the walk is complete.
+func (s Source) Walk(opts Options, visit Visitor) error {
+	root, err := s.Read(opts)
+	if root == nil {
+		return err
+	}
+	if visitErr := Visit(root, visit); visitErr != nil {
+		return visitErr
+	}
+	return err
+}
+
+// Walk reads the tree of the directory root and visits it.
+func Walk(root string, opts Options, visit Visitor) error {
+	return DirSource(root).Walk(opts, visit)
+}
@@ -0,0 +1,121 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWalk(t *testing.T) {
+	var got []string
+	err := Walk("../testdata", Options{Files: true, MaxDepth: 2}, func(n *Node, pos Position) error {
+		got = append(got, fmt.Sprintf("%d %v %q %s", pos.Depth, pos.Last, pos.Prefix, pos.Path))
+		if n.Info.Name() == "static" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		`1 false "" project`,
+		`2 false "│\t" project/file.txt`,
+		`2 true "│\t" project/gopher.png`,
+		`1 false "" static`,
+		`1 false "" zline`,
+		`2 false "│\t" zline/empty.txt`,
+		`2 true "│\t" zline/lorem`,
+		`1 true "" zzfile.txt`,
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestWalkStop(t *testing.T) {
+	stop := errors.New("stop")
+	visited := 0
+	err := Walk("../testdata", Options{}, func(n *Node, pos Position) error {
+		visited++
+		return stop
+	})
+	if err != stop || visited != 1 {
+		t.Errorf("expected the visitor error after one node, got %v after %d", err, visited)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	denied := errors.New("permission denied")
+	opts := Options{ReadDir: func(fsys fs.FS, name string) ([]fs.FileInfo, error) {
+		if name == "zline" {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: denied}
+		}
+		return ReadDir(fsys, name)
+	}}
+
+	root, err := Read("../testdata", opts)
+	errs, ok := err.(Errors)
+	if root == nil || !ok || len(errs) != 1 || !errors.Is(err, denied) {
+		t.Fatalf("expected one error for zline, got %v", err)
+	}
+	if !strings.Contains(errs[0].Error(), "testdata/zline") {
+		t.Errorf("error path is not rooted: %v", errs[0])
+	}
+	for _, child := range root.Children {
+		if (child.Err != nil) != (child.Info.Name() == "zline") {
+			t.Errorf("%s: unexpected error %v", child.Info.Name(), child.Err)
+		}
+	}
+
+	opts.Strict = true
+	if root, err := Read("../testdata", opts); root != nil || !errors.Is(err, denied) {
+		t.Errorf("strict: expected only the error, got %v, %v", root, err)
+	}
+}
+
+// changingFS lists the entries of a MapFS but fails to stat those in stat,
+// like a directory changing while it is read.
+type changingFS struct {
+	fstest.MapFS
+	stat map[string]error
+}
+
+type changingEntry struct {
+	fs.DirEntry
+	err error
+}
+
+func (e changingEntry) Info() (fs.FileInfo, error) {
+	return nil, &fs.PathError{Op: "lstat", Path: e.Name(), Err: e.err}
+}
+
+func (fsys changingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fsys.MapFS.ReadDir(name)
+	for i, entry := range entries {
+		if err, ok := fsys.stat[entry.Name()]; ok {
+			entries[i] = changingEntry{entry, err}
+		}
+	}
+	return entries, err
+}
+
+func TestReadDirRemoved(t *testing.T) {
+	fsys := changingFS{
+		MapFS: fstest.MapFS{"a": {}, "b": {}, "c": {}},
+		stat:  map[string]error{"b": fs.ErrNotExist},
+	}
+	infos, err := ReadDir(fsys, ".")
+	if err != nil || len(infos) != 2 || infos[0].Name() != "a" || infos[1].Name() != "c" {
+		t.Errorf("expected a and c without b, got %v, %v", infos, err)
+	}
+
+	denied := errors.New("permission denied")
+	fsys.stat["b"] = denied
+	if _, err := ReadDir(fsys, "."); !errors.Is(err, denied) {
+		t.Errorf("expected other errors to fail the directory, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package tree
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ReadDir lists a directory of fsys with the information of every entry.
+// It is the default for Options.ReadDir. Entries removed after the
+// directory was read are left out, as ioutil.ReadDir does.
+func ReadDir(fsys fs.FS, name string) ([]fs.FileInfo, error) {
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func cleanupList(list []fs.FileInfo, opts Options) (result []fs.FileInfo) {
+	if !opts.Files {
+		for _, f := range list {
+			if f.IsDir() {
+				result = append(result, f)
+			}
+		}
+	} else {
+		result = append(result, list...)
+	}
+	less := lessFunc(opts)
+	sort.Slice(result, func(i, j int) bool {
+		return less(result[i], result[j])
+	})
+	return
+}
+
+type walker struct {
+	src  Source
+	opts Options
+	// sem holds a slot per extra goroutine; a nil sem walks serially
+	sem chan struct{}
+	wg  sync.WaitGroup
+	// failed is set by the first error in strict mode to stop the walk
+	failed int32
+}
+
+// readEntries returns the sorted entries to show for name.
+func (w *walker) readEntries(name string, ignore *ignoreList) ([]fs.FileInfo, *ignoreList, error) {
+	readDir := w.opts.ReadDir
+	if readDir == nil {
+		readDir = ReadDir
+	}
+	entries, err := readDir(w.src.FS, name)
+	if err != nil {
+		return nil, ignore, err
+	}
+	entries = w.src.ResolveLinks(name, entries)
+	if w.opts.Gitignore {
+		if ignore, err = ignore.load(w.src.FS, name); err != nil {
+			return nil, ignore, err
+		}
+	}
+	entries = filterList(entries, name, w.opts, ignore)
+	return cleanupList(entries, w.opts), ignore, nil
+}
+
+// walkDir reads the directory name into dir. Subdirectories are handed to
+// another goroutine while a slot is free and walked inline otherwise, so the
+// pool never deadlocks waiting on itself.
+func (w *walker) walkDir(dir *Node, name string, depth int, ignore *ignoreList, seen *visit) {
+	if w.opts.MaxDepth > 0 && depth > w.opts.MaxDepth {
+		return
+	}
+	if atomic.LoadInt32(&w.failed) != 0 {
+		return
+	}
+	entries, ignore, err := w.readEntries(name, ignore)
+	if err != nil {
+		dir.Err = w.src.PathError(err)
+		if w.opts.Strict {
+			atomic.StoreInt32(&w.failed, 1)
+		}
+		return
+	}
+	for _, entry := range entries {
+		dir.Children = append(dir.Children, &Node{Info: entry})
+	}
+	for _, child := range dir.Children {
+		if !child.Info.IsDir() {
+			continue
+		}
+		if _, isLink := child.Info.(*Link); isLink {
+			if !w.opts.FollowLinks {
+				continue
+			}
+			if id, ok := fileKey(child.Info); ok && seen.contains(id) {
+				child.Recursive = true
+				continue
+			}
+		}
+		child, childName, childSeen := child, path.Join(name, child.Info.Name()), seen.push(child.Info)
+		select {
+		case w.sem <- struct{}{}:
+			w.wg.Add(1)
+			go func() {
+				defer w.wg.Done()
+				w.walkDir(child, childName, depth+1, ignore, childSeen)
+				<-w.sem
+			}()
+		default:
+			w.walkDir(child, childName, depth+1, ignore, childSeen)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func makeTree(tb testing.TB, root string, depth, fanout int) {
+	for i := 0; i < fanout; i++ {
+		name := filepath.Join(root, fmt.Sprintf("file%d.txt", i))
+		if err := ioutil.WriteFile(name, bytes.Repeat([]byte("x"), i), 0644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if depth == 0 {
+		return
+	}
+	for i := 0; i < fanout; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("dir%d", i))
+		if err := os.Mkdir(dir, 0755); err != nil {
+			tb.Fatal(err)
+		}
+		makeTree(tb, dir, depth-1, fanout)
+	}
+}
+
+func TestTreeParallel(t *testing.T) {
+	generated := t.TempDir()
+	makeTree(t, generated, 3, 5)
+
+	for _, path := range []string{"testdata", generated} {
+		serial := new(bytes.Buffer)
+		if err := renderTree(serial, path, options{printFiles: true}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, workers := range []int{2, 4, 16} {
+			parallel := new(bytes.Buffer)
+			if err := renderTree(parallel, path, options{printFiles: true, workers: workers}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if parallel.String() != serial.String() {
+				t.Errorf("%s with %d workers: output differs from serial walk\nGot:\n%v\nExpected:\n%v",
+					path, workers, parallel.String(), serial.String())
+			}
+		}
+	}
+}
+
+func benchmarkTree(b *testing.B, path string, workers int) {
+	for i := 0; i < b.N; i++ {
+		if err := renderTree(ioutil.Discard, path, options{printFiles: true, workers: workers}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTreeTestdata(b *testing.B) {
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			benchmarkTree(b, "testdata", workers)
+		})
+	}
+}
+
+func BenchmarkTreeGenerated(b *testing.B) {
+	root := b.TempDir()
+	makeTree(b, root, 3, 8)
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			benchmarkTree(b, root, workers)
+		})
+	}
+}
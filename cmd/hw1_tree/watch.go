@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// watchDelay batches the burst of events a single build step usually causes
+// into one re-render.
+var watchDelay = 100 * time.Millisecond
+
+// dirWatcher reports changes in the directories passed to add. The events
+// channel receives nil for every batch of changes and an error if watching
+// broke down.
+type dirWatcher interface {
+	add(dir string) error
+	events() <-chan error
+	close() error
+}
+
+// watchDirs subscribes to every directory of the walked tree. Adding a
+// directory that is already watched is cheap, so this runs after every walk
+// to pick up new subdirectories. Directories that could not be read are
+// watched too, a change inside them retries the walk; failing to watch one
+// of those does not end the watch.
+func watchDirs(w dirWatcher, dir string, n *node) error {
+	if !n.info.IsDir() || n.recursive {
+		return nil
+	}
+	if err := w.add(dir); err != nil && n.err == nil {
+		return err
+	}
+	for _, child := range n.children {
+		if err := watchDirs(w, filepath.Join(dir, child.info.Name()), child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printEvents prints one line per added, removed or changed entry below dir.
+func printEvents(out io.Writer, dir string, entry *diffEntry, opts options) error {
+	for _, child := range entry.children {
+		name := path.Join(dir, child.name)
+		if child.status != statusSame {
+			if _, err := fmt.Fprintf(out, "%c %s%s\n", child.status, name, child.details(opts)); err != nil {
+				return err
+			}
+		}
+		if err := printEvents(out, name, child, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchTree prints the tree of dir and keeps it in memory, then re-walks it
+// whenever something changes and either prints it again or, with events set,
+// only the entries that were added, removed or changed. It runs until stop is
+// closed or watching fails.
+func watchTree(out io.Writer, dir string, opts options, stop <-chan struct{}) error {
+	src, err := openSource(dir)
+	if err != nil {
+		return err
+	}
+	src.close()
+	if src.dir == "" {
+		return errors.New("--watch needs a directory")
+	}
+	if opts.events {
+		opts.printFiles = true
+	}
+
+	w, err := newWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.close()
+
+	var prev *document
+	for {
+		// unreadable directories are marked in the output and retried on
+		// the next change instead of ending the watch
+		root, walkErr := readTree(src, opts)
+		if root == nil {
+			return walkErr
+		}
+		if err := watchDirs(w, dir, root); err != nil {
+			return err
+		}
+		doc := newDocument(dir, root, opts)
+		switch {
+		case !opts.events:
+			if prev != nil {
+				fmt.Fprintln(out)
+			}
+			err = renderNode(out, dir, src, root, opts)
+		case prev != nil:
+			err = printEvents(out, "", diffDocuments("", prev, doc), opts)
+		}
+		if err != nil {
+			return err
+		}
+		prev = doc
+
+		select {
+		case <-stop:
+			return nil
+		case err := <-w.events():
+			if err != nil {
+				return err
+			}
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(watchDelay):
+		}
+		// the walk below sees everything that happened meanwhile
+		for pending := true; pending; {
+			select {
+			case err := <-w.events():
+				if err != nil {
+					return err
+				}
+			default:
+				pending = false
+			}
+		}
+	}
+}
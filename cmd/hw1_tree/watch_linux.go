@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_ATTRIB |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotifyWatcher does not look at the events it reads, any of them makes
+// watchTree walk the tree again.
+type inotifyWatcher struct {
+	fd      int
+	file    *os.File
+	changes chan error
+}
+
+func newWatcher() (dirWatcher, error) {
+	// a non-blocking descriptor lets the runtime poller wait on it, so
+	// closing the file ends a pending read
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	w := &inotifyWatcher{
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		changes: make(chan error, 1),
+	}
+	go w.read()
+	return w, nil
+}
+
+func (w *inotifyWatcher) read() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		if _, err := w.file.Read(buf); err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.changes <- err
+			}
+			return
+		}
+		select {
+		case w.changes <- nil:
+		default:
+		}
+	}
+}
+
+// add watches dir. The kernel returns the existing watch for a directory
+// that is already watched and drops watches of removed ones by itself. A
+// directory removed or replaced since the walk is skipped, the event of its
+// parent walks the tree again.
+func (w *inotifyWatcher) add(dir string) error {
+	_, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask|syscall.IN_ONLYDIR)
+	if err == syscall.ENOENT || err == syscall.ENOTDIR {
+		return nil
+	}
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	return nil
+}
+
+func (w *inotifyWatcher) events() <-chan error {
+	return w.changes
+}
+
+func (w *inotifyWatcher) close() error {
+	return w.file.Close()
+}
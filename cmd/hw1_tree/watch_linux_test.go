@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func waitOutput(t *testing.T, out *syncBuffer, want string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %q, got:\n%v", want, out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func startWatch(t *testing.T, root string, opts options) (*syncBuffer, func()) {
+	out := new(syncBuffer)
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- watchTree(out, root, opts, stop)
+	}()
+	return out, func() {
+		close(stop)
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"build/old.o": "old"})
+	out, stop := startWatch(t, root, options{events: true})
+	defer stop()
+
+	// nothing is printed for the initial walk, give it time to subscribe
+	time.Sleep(2 * watchDelay)
+	writeFiles(t, root, map[string]string{"build/lib/a.o": "aaaa"})
+	waitOutput(t, out, "+ build/lib/a.o (4b)\n")
+	if !strings.HasPrefix(out.String(), "+ build/lib\n") {
+		t.Errorf("expected the new directory first, got:\n%v", out.String())
+	}
+
+	// the directory created above must be watched as well
+	writeFiles(t, root, map[string]string{"build/lib/b.o": "b"})
+	waitOutput(t, out, "+ build/lib/b.o (1b)\n")
+
+	if err := os.Remove(filepath.Join(root, "build", "old.o")); err != nil {
+		t.Fatal(err)
+	}
+	waitOutput(t, out, "- build/old.o (3b)\n")
+}
+
+func TestWatchRender(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.txt": "a"})
+	out, stop := startWatch(t, root, options{printFiles: true})
+	defer stop()
+
+	waitOutput(t, out, "└───a.txt (1b)\n")
+	writeFiles(t, root, map[string]string{"b.txt": "bb"})
+	expected := "└───a.txt (1b)\n\n├───a.txt (1b)\n└───b.txt (2b)\n"
+	waitOutput(t, out, expected)
+}
+
+func TestWatchNeedsDirectory(t *testing.T) {
+	if err := watchTree(new(bytes.Buffer), "testdata/zzfile.txt", options{}, nil); err == nil {
+		t.Error("expected an error for a file")
+	}
+}
+
+func TestWatchAddRemoved(t *testing.T) {
+	w, err := newWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.close()
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"file.txt": "a"})
+	for _, dir := range []string{"missing", "file.txt"} {
+		if err := w.add(filepath.Join(root, dir)); err != nil {
+			t.Errorf("%s: unexpected error: %v", dir, err)
+		}
+	}
+}
+
+func TestWatchUnreadableDir(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"build/old.o": "old"})
+	// build cannot be listed by the first walk only
+	var denied int32
+	orig := readDir
+	readDir = func(fsys fs.FS, name string) ([]os.FileInfo, error) {
+		if name == "build" && atomic.CompareAndSwapInt32(&denied, 0, 1) {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: errDenied}
+		}
+		return orig(fsys, name)
+	}
+	defer func() { readDir = orig }()
+	out, stop := startWatch(t, root, options{events: true})
+	defer stop()
+
+	time.Sleep(2 * watchDelay)
+	writeFiles(t, root, map[string]string{"build/new.o": "new"})
+	waitOutput(t, out, "+ build/new.o (3b)\n")
+}
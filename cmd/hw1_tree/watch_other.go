@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import "errors"
+
+func newWatcher() (dirWatcher, error) {
+	return nil, errors.New("--watch is only supported on linux")
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Apply is a stage calling f for every value. The first error stops it.
+func Apply[In, Out any](f func(ctx context.Context, v In) (Out, error)) Stage[In, Out] {
+	return func(ctx context.Context, in chan In, out chan Out) error {
+		for v := range in {
+			r, err := f(ctx, v)
+			if err != nil {
+				return err
+			}
+			if err := send(ctx, out, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Parallel runs stage on up to n values at once, each value in a run of its
+// own. If ordered is set what the runs send comes out in input order, keeping
+// at most n values waiting; otherwise it comes out as soon as a run is done.
+func Parallel[In, Out any](n int, ordered bool, stage Stage[In, Out]) Stage[In, Out] {
+	if n < 1 {
+		n = 1
+	}
+	return func(ctx context.Context, in chan In, out chan Out) error {
+		type item struct {
+			v       In
+			results chan []Out
+		}
+		items := make(chan item)
+		// the results of the runs in input order
+		pending := make(chan chan []Out, n)
+
+		g := newGroup(ctx)
+		g.run(0, func(ctx context.Context) error {
+			for {
+				select {
+				case v, ok := <-in:
+					if !ok {
+						return nil
+					}
+					it := item{v, make(chan []Out, 1)}
+					if ordered {
+						if err := send(ctx, pending, it.results); err != nil {
+							return err
+						}
+					}
+					if err := send(ctx, items, it); err != nil {
+						return err
+					}
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}, func() {
+			close(items)
+			close(pending)
+		})
+		for i := 0; i < n; i++ {
+			g.run(1+i, func(ctx context.Context) error {
+				for it := range items {
+					results, err := Collect(ctx, stage, it.v)
+					if err != nil {
+						return err
+					}
+					if ordered {
+						it.results <- results
+						continue
+					}
+					for _, r := range results {
+						if err := send(ctx, out, r); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			}, func() {})
+		}
+		if ordered {
+			g.run(1+n, func(ctx context.Context) error {
+				for results := range pending {
+					select {
+					case rs := <-results:
+						for _, r := range rs {
+							if err := send(ctx, out, r); err != nil {
+								return err
+							}
+						}
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+				return nil
+			}, func() {})
+		}
+		return g.wait()
+	}
+}
+
+// FanOut runs all stages on the same input, every value going to whichever
+// stage reads it first, and merges what they send.
+func FanOut[In, Out any](stages ...Stage[In, Out]) Stage[In, Out] {
+	return func(ctx context.Context, in chan In, out chan Out) error {
+		g := newGroup(ctx)
+		for i, s := range stages {
+			stage := s
+			g.run(i, func(ctx context.Context) error {
+				return stage(ctx, in, out)
+			}, func() {})
+		}
+		return g.wait()
+	}
+}
+
+// Merge is a stage passing on its input together with everything sent on
+// sources, until all of them are closed.
+func Merge[T any](sources ...chan T) Stage[T, T] {
+	return func(ctx context.Context, in chan T, out chan T) error {
+		g := newGroup(ctx)
+		for i, src := range append([]chan T{in}, sources...) {
+			ch := src
+			g.run(i, func(ctx context.Context) error {
+				for v := range ch {
+					if err := send(ctx, out, v); err != nil {
+						return err
+					}
+				}
+				return nil
+			}, func() {
+				drain(ch)
+			})
+		}
+		return g.wait()
+	}
+}
+
+// Tee is a stage passing on every value and sending a copy to side, which it
+// closes once its input is.
+func Tee[T any](side chan T) Stage[T, T] {
+	return func(ctx context.Context, in chan T, out chan T) error {
+		defer close(side)
+		for v := range in {
+			if err := send(ctx, side, v); err != nil {
+				return err
+			}
+			if err := send(ctx, out, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Batch groups values into slices of size, sending a shorter one when
+// timeout has passed since its first value arrived or the input is closed.
+func Batch[T any](size int, timeout time.Duration) Stage[T, []T] {
+	if size < 1 {
+		size = 1
+	}
+	return func(ctx context.Context, in chan T, out chan []T) error {
+		var batch []T
+		var deadline <-chan time.Time
+		flush := func() error {
+			b := batch
+			batch, deadline = nil, nil
+			return send(ctx, out, b)
+		}
+
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					if len(batch) > 0 {
+						return flush()
+					}
+					return nil
+				}
+				if len(batch) == 0 {
+					deadline = time.After(timeout)
+				}
+				batch = append(batch, v)
+				if len(batch) < size {
+					continue
+				}
+			case <-deadline:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallel(t *testing.T) {
+	var running, maxRunning int32
+	// later values finish first
+	slow := Map(func(v int) int {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Duration(10-v) * 5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return v
+	})
+	inputs := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+
+	got, err := Collect(context.Background(), Parallel(3, true, slow), inputs...)
+	if err != nil || fmt.Sprint(got) != fmt.Sprint(inputs) {
+		t.Errorf("ordered: expected %v, got %v, err %v", inputs, got, err)
+	}
+	if maxRunning != 3 {
+		t.Errorf("expected 3 values at once, got %d", maxRunning)
+	}
+
+	got, err = Collect(context.Background(), Parallel(10, false, slow), inputs...)
+	if err != nil || got[0] != 9 {
+		t.Errorf("unordered: expected the fastest value first, got %v, err %v", got, err)
+	}
+	sort.Ints(got)
+	if fmt.Sprint(got) != fmt.Sprint(inputs) {
+		t.Errorf("unordered: expected all values, got %v", got)
+	}
+
+	errBad := errors.New("bad value")
+	failing := Apply(func(ctx context.Context, v int) (int, error) {
+		if v == 5 {
+			return 0, errBad
+		}
+		return v, nil
+	})
+	if _, err := Collect(context.Background(), Parallel(3, true, failing), inputs...); err != errBad {
+		t.Errorf("expected the stage error, got %v", err)
+	}
+}
+
+func TestFanOutMerge(t *testing.T) {
+	var first, second int32
+	count := func(n *int32) Stage[int, int] {
+		return Map(func(v int) int {
+			atomic.AddInt32(n, 1)
+			time.Sleep(time.Millisecond)
+			return v
+		})
+	}
+	got, err := Collect(context.Background(), FanOut(count(&first), count(&second)), 1, 2, 3, 4, 5, 6, 7, 8)
+	sort.Ints(got)
+	if err != nil || fmt.Sprint(got) != "[1 2 3 4 5 6 7 8]" {
+		t.Errorf("expected every value once, got %v, err %v", got, err)
+	}
+	if first == 0 || second == 0 {
+		t.Errorf("expected both stages to get values, got %d and %d", first, second)
+	}
+
+	// tee every value aside and merge the copies back in
+	side := make(chan int, stageBuffer)
+	got, err = Collect(context.Background(), Then(Tee(side), Merge(side)), 1, 2, 3)
+	sort.Ints(got)
+	if err != nil || fmt.Sprint(got) != "[1 1 2 2 3 3]" {
+		t.Errorf("expected every value twice, got %v, err %v", got, err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	got, err := Collect(context.Background(), Batch[int](2, time.Second), 1, 2, 3, 4, 5)
+	if err != nil || fmt.Sprint(got) != "[[1 2] [3 4] [5]]" {
+		t.Errorf("expected batches of 2, got %v, err %v", got, err)
+	}
+
+	// the first value waits no longer than the timeout for a second one
+	in, out := make(chan int), make(chan []int, 1)
+	go Batch[int](2, 10*time.Millisecond)(context.Background(), in, out)
+	in <- 1
+	select {
+	case b := <-out:
+		if fmt.Sprint(b) != "[1]" {
+			t.Errorf("expected [1], got %v", b)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("the batch was not sent after the timeout")
+	}
+	close(in)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultSingleHash is the formula of SingleHash.
+	DefaultSingleHash = "crc32(x)~crc32(md5(x))"
+	// DefaultMultiHash is the formula of MultiHash.
+	DefaultMultiHash = "crc32(0x)crc32(1x)crc32(2x)crc32(3x)crc32(4x)crc32(5x)"
+)
+
+// term is a part of a formula: literal text, the input x, or a signer
+// applied to the concatenation of arg.
+type term struct {
+	lit    string
+	input  bool
+	signer Signer
+	arg    []term
+}
+
+// Formula computes a hash out of registered signers, for example
+// crc32(x)~crc32(md5(x)). Calls are written name(...), x is the value being
+// hashed and everything else is copied as it is; text containing letters or
+// parentheses is quoted with '. Concatenated calls run concurrently.
+type Formula struct {
+	spec  string
+	terms []term
+}
+
+// ParseFormula parses spec. Every signer it names has to be registered.
+func ParseFormula(spec string) (*Formula, error) {
+	p := &formulaParser{spec: spec}
+	terms, err := p.concat()
+	if err == nil && p.pos < len(spec) {
+		err = p.errorf("unexpected )")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Formula{spec, terms}, nil
+}
+
+func mustParseFormula(spec string) *Formula {
+	f, err := ParseFormula(spec)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func (f *Formula) String() string {
+	return f.spec
+}
+
+// Sign computes the formula for x.
+func (f *Formula) Sign(x string) string {
+	h, _ := f.SignContext(context.Background(), x)
+	return h
+}
+
+// SignContext computes the formula for x, giving up when ctx is done while
+// a signer waits for its limiter.
+func (f *Formula) SignContext(ctx context.Context, x string) (string, error) {
+	return evalTerms(ctx, f.terms, x)
+}
+
+func evalTerms(ctx context.Context, terms []term, x string) (string, error) {
+	parts := make([]string, len(terms))
+	errs := make([]error, len(terms))
+	var wg sync.WaitGroup
+	for i, t := range terms {
+		switch {
+		case t.input:
+			parts[i] = x
+		case t.signer == nil:
+			parts[i] = t.lit
+		default:
+			wg.Add(1)
+			go func(i int, t term) {
+				defer wg.Done()
+				parts[i], errs[i] = evalCall(ctx, t, x)
+			}(i, t)
+		}
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(parts, ""), nil
+}
+
+func evalCall(ctx context.Context, t term, x string) (string, error) {
+	arg, err := evalTerms(ctx, t.arg, x)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := t.signer.(ContextSigner); ok {
+		return s.SignContext(ctx, arg)
+	}
+	return t.signer.Sign(arg), nil
+}
+
+type formulaParser struct {
+	spec string
+	pos  int
+}
+
+func (p *formulaParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("formula %q at %d: %s", p.spec, p.pos, fmt.Sprintf(format, args...))
+}
+
+// concat parses terms up to a closing parenthesis or the end of the spec.
+func (p *formulaParser) concat() ([]term, error) {
+	var terms []term
+	for p.pos < len(p.spec) {
+		c := p.spec[p.pos]
+		switch {
+		case c == ')':
+			return terms, nil
+		case c == '(':
+			return nil, p.errorf("unexpected (")
+		case c == '\'':
+			end := strings.IndexByte(p.spec[p.pos+1:], '\'')
+			if end < 0 {
+				return nil, p.errorf("unterminated quote")
+			}
+			terms = append(terms, term{lit: p.spec[p.pos+1 : p.pos+1+end]})
+			p.pos += end + 2
+		case isLetter(c):
+			t, err := p.call()
+			if err != nil {
+				return nil, err
+			}
+			terms = append(terms, t)
+		default:
+			start := p.pos
+			for p.pos < len(p.spec) && !strings.ContainsRune("()'", rune(p.spec[p.pos])) && !isLetter(p.spec[p.pos]) {
+				p.pos++
+			}
+			terms = append(terms, term{lit: p.spec[start:p.pos]})
+		}
+	}
+	return terms, nil
+}
+
+// call parses x or name(...).
+func (p *formulaParser) call() (term, error) {
+	start := p.pos
+	for p.pos < len(p.spec) && isIdentByte(p.spec[p.pos]) {
+		p.pos++
+	}
+	name := p.spec[start:p.pos]
+	if p.pos == len(p.spec) || p.spec[p.pos] != '(' {
+		if name == "x" {
+			return term{input: true}, nil
+		}
+		p.pos = start
+		return term{}, p.errorf("expected x or a call, got %q", name)
+	}
+	s, ok := lookupSigner(name)
+	if !ok {
+		p.pos = start
+		return term{}, p.errorf("unknown signer %q", name)
+	}
+	p.pos++
+	arg, err := p.concat()
+	if err != nil {
+		return term{}, err
+	}
+	if p.pos == len(p.spec) {
+		return term{}, p.errorf("missing )")
+	}
+	p.pos++
+	return term{signer: s, arg: arg}, nil
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isIdentByte(c byte) bool {
+	return isLetter(c) || '0' <= c && c <= '9' || c == '-'
+}
+
+func isIdent(name string) bool {
+	if name == "" || !isLetter(name[0]) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isIdentByte(name[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-webservices/cmd/hw2_signer/memo"
+)
+
+func TestFormula(t *testing.T) {
+	RegisterSigner("test-slow", SignerFunc(func(data string) string {
+		time.Sleep(50 * time.Millisecond)
+		return "<" + data + ">"
+	}))
+	defer func() {
+		signersMu.Lock()
+		delete(signers, "test-slow")
+		signersMu.Unlock()
+	}()
+
+	cases := []struct {
+		spec     string
+		expected string
+	}{
+		{"x", "abc"},
+		{"test-slow(x)~test-slow(test-slow(x))", "<abc>~<<abc>>"},
+		{"test-slow(0x)test-slow(1x)", "<0abc><1abc>"},
+		{"'sign:'test-slow(x 'and' x)", "sign:<abc and abc>"},
+		{"test-slow()", "<>"},
+	}
+	for _, c := range cases {
+		f, err := ParseFormula(c.spec)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.spec, err)
+			continue
+		}
+		if got := f.Sign("abc"); got != c.expected {
+			t.Errorf("%s: results not match\nGot: %v\nExpected: %v", c.spec, got, c.expected)
+		}
+	}
+
+	// concatenated calls run concurrently
+	f := mustParseFormula("test-slow(x)test-slow(x)test-slow(x)test-slow(x)")
+	start := time.Now()
+	f.Sign("abc")
+	if end := time.Since(start); end > 150*time.Millisecond {
+		t.Errorf("calls did not run concurrently, took %s", end)
+	}
+
+	for spec, msg := range map[string]string{
+		"nope(x)":         "unknown signer",
+		"test-slow(x":     "missing )",
+		"test-slow(x))":   "unexpected )",
+		"(x)":             "unexpected (",
+		"test-slow(y)":    "expected x or a call",
+		"'unterminated x": "unterminated quote",
+	} {
+		if _, err := ParseFormula(spec); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%s: expected an error with %q, got %v", spec, msg, err)
+		}
+	}
+}
+
+func TestHashJob(t *testing.T) {
+	var got []string
+	hash, err := HashJob("sha256(x)", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ExecutePipeline(
+		job(func(in, out chan interface{}) {
+			out <- "abc"
+			out <- 1
+		}),
+		hash,
+		job(func(in, out chan interface{}) {
+			for v := range in {
+				got = append(got, v.(string))
+			}
+		}),
+	)
+	expected := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad " +
+		"6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", got, expected)
+	}
+
+	if _, err := HashJob("md5(x", false); err == nil {
+		t.Errorf("expected an error for a broken formula")
+	}
+}
+
+func TestCachedHashJob(t *testing.T) {
+	md5, crc32 := DataSignerMd5, DataSignerCrc32
+	defer func() {
+		DataSignerMd5, DataSignerCrc32 = md5, crc32
+	}()
+	var md5Calls, crc32Calls int32
+	DataSignerMd5 = func(data string) string {
+		atomic.AddInt32(&md5Calls, 1)
+		return "md5(" + data + ")"
+	}
+	DataSignerCrc32 = func(data string) string {
+		atomic.AddInt32(&crc32Calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return "crc(" + data + ")"
+	}
+
+	path := filepath.Join(t.TempDir(), "signatures.json")
+	sign := func() string {
+		c, err := memo.Open(path, 100)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		single, err := CachedHashJob(DefaultSingleHash, false, c)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		multi, err := CachedHashJob(DefaultMultiHash, false, c)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var result string
+		ExecutePipeline(
+			job(func(in, out chan interface{}) {
+				for _, v := range []int{0, 1, 1, 2, 3, 5, 8} {
+					out <- v
+				}
+			}),
+			single, multi, CombineResults,
+			job(func(in, out chan interface{}) {
+				result = (<-in).(string)
+			}),
+		)
+		if err := c.Save(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return result
+	}
+
+	// the repeated 1 is hashed once
+	first := sign()
+	if md5Calls != 6 || crc32Calls != 6*8 {
+		t.Errorf("expected 6 md5 and 48 crc32 calls, got %d and %d", md5Calls, crc32Calls)
+	}
+	// and nothing is hashed again by the next run
+	if second := sign(); second != first {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", second, first)
+	}
+	if md5Calls != 6 || crc32Calls != 6*8 {
+		t.Errorf("expected no more calls, got %d md5 and %d crc32", md5Calls, crc32Calls)
+	}
+}
+
+func TestCachedSalt(t *testing.T) {
+	crc32, salt := DataSignerCrc32, DataSignerSalt
+	defer func() {
+		DataSignerCrc32, DataSignerSalt = crc32, salt
+	}()
+	var calls int32
+	DataSignerCrc32 = func(data string) string {
+		atomic.AddInt32(&calls, 1)
+		return "crc(" + data + DataSignerSalt + ")"
+	}
+
+	path := filepath.Join(t.TempDir(), "signatures.json")
+	sign := func() string {
+		c, err := memo.Open(path, 100)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		h := Cached(mustParseFormula("crc32(x)"), c, "crc32(x)").Sign("abc")
+		if err := c.Save(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return h
+	}
+
+	DataSignerSalt = "one"
+	if h := sign(); h != "crc(abcone)" || calls != 1 {
+		t.Errorf("expected crc(abcone) from one call, got %v from %d", h, calls)
+	}
+	// a file saved under another salt is no help
+	DataSignerSalt = "two"
+	if h := sign(); h != "crc(abctwo)" || calls != 2 {
+		t.Errorf("expected crc(abctwo) from a new call, got %v from %d", h, calls)
+	}
+	DataSignerSalt = "one"
+	if h := sign(); h != "crc(abcone)" || calls != 2 {
+		t.Errorf("expected the saved crc(abcone), got %v from %d calls", h, calls)
+	}
+}
@@ -0,0 +1,236 @@
+// Package limiter bounds how often and how many callers may go on at once:
+// a token bucket for rates, a semaphore for concurrency and PerKey for
+// separate limits per key. Every limiter honors context cancellation and
+// counts how long callers waited.
+package limiter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limiter lets callers go on within its limits.
+type Limiter interface {
+	// Acquire waits until the caller may go on or ctx is done. Limiters that
+	// are not per key ignore key. release has to be called once the caller
+	// is done, it does nothing for rate limits.
+	Acquire(ctx context.Context, key string) (release func(), err error)
+	// Stats returns what the limiter counted so far.
+	Stats() Stats
+}
+
+// Stats count the calls to Acquire.
+type Stats struct {
+	// Acquired counts the callers that went on and Canceled those whose
+	// context was done first
+	Acquired, Canceled uint64
+	// Waited counts the callers that could not go on right away
+	Waited uint64
+	// WaitTime is the total time callers waited and MaxWait the longest
+	WaitTime, MaxWait time.Duration
+}
+
+// metrics are updated atomically by the limiters.
+type metrics struct {
+	acquired, canceled, waited uint64
+	waitTime, maxWait          int64
+}
+
+// observe counts a call to Acquire that started at start and waited if
+// waited is set.
+func (m *metrics) observe(start time.Time, waited bool, err error) {
+	if err != nil {
+		atomic.AddUint64(&m.canceled, 1)
+	} else {
+		atomic.AddUint64(&m.acquired, 1)
+	}
+	if !waited {
+		return
+	}
+	d := int64(time.Since(start))
+	atomic.AddUint64(&m.waited, 1)
+	atomic.AddInt64(&m.waitTime, d)
+	for {
+		max := atomic.LoadInt64(&m.maxWait)
+		if d <= max || atomic.CompareAndSwapInt64(&m.maxWait, max, d) {
+			return
+		}
+	}
+}
+
+func (m *metrics) Stats() Stats {
+	return Stats{
+		Acquired: atomic.LoadUint64(&m.acquired),
+		Canceled: atomic.LoadUint64(&m.canceled),
+		Waited:   atomic.LoadUint64(&m.waited),
+		WaitTime: time.Duration(atomic.LoadInt64(&m.waitTime)),
+		MaxWait:  time.Duration(atomic.LoadInt64(&m.maxWait)),
+	}
+}
+
+func noRelease() {}
+
+// Semaphore lets at most n callers go on at once.
+type Semaphore struct {
+	metrics
+	slots chan struct{}
+}
+
+// NewSemaphore returns a Semaphore for n callers, at least one.
+func NewSemaphore(n int) *Semaphore {
+	if n < 1 {
+		n = 1
+	}
+	return &Semaphore{slots: make(chan struct{}, n)}
+}
+
+func (s *Semaphore) Acquire(ctx context.Context, key string) (func(), error) {
+	start := time.Now()
+	select {
+	case s.slots <- struct{}{}:
+		s.observe(start, false, nil)
+		return s.release, nil
+	default:
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		s.observe(start, true, nil)
+		return s.release, nil
+	case <-ctx.Done():
+		s.observe(start, true, ctx.Err())
+		return noRelease, ctx.Err()
+	}
+}
+
+func (s *Semaphore) release() {
+	<-s.slots
+}
+
+// TokenBucket lets callers go on at rate per second on average, with up to
+// burst of them at once after a quiet period.
+type TokenBucket struct {
+	metrics
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket returns a full TokenBucket. rate has to be positive, burst
+// is at least one.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token, going into debt if there is none, and returns how
+// long to wait until the debt is paid.
+func (b *TokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// unreserve gives back the token of a caller that stopped waiting.
+func (b *TokenBucket) unreserve() {
+	b.mu.Lock()
+	b.tokens++
+	b.mu.Unlock()
+}
+
+func (b *TokenBucket) Acquire(ctx context.Context, key string) (func(), error) {
+	start := time.Now()
+	wait := b.reserve(start)
+	if wait == 0 {
+		b.observe(start, false, nil)
+		return noRelease, nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		b.observe(start, true, nil)
+		return noRelease, nil
+	case <-ctx.Done():
+		b.unreserve()
+		b.observe(start, true, ctx.Err())
+		return noRelease, ctx.Err()
+	}
+}
+
+// PerKey keeps a limiter of its own for every key.
+type PerKey struct {
+	newLimiter func() Limiter
+
+	mu   sync.Mutex
+	keys map[string]Limiter
+}
+
+// NewPerKey returns a PerKey making the limiter of a key with newLimiter
+// the first time it is used.
+func NewPerKey(newLimiter func() Limiter) *PerKey {
+	return &PerKey{newLimiter: newLimiter, keys: make(map[string]Limiter)}
+}
+
+func (p *PerKey) limiter(key string) Limiter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.keys[key]
+	if !ok {
+		l = p.newLimiter()
+		p.keys[key] = l
+	}
+	return l
+}
+
+func (p *PerKey) Acquire(ctx context.Context, key string) (func(), error) {
+	return p.limiter(key).Acquire(ctx, key)
+}
+
+// Stats adds up the stats of all keys.
+func (p *PerKey) Stats() Stats {
+	var total Stats
+	for _, s := range p.KeyStats() {
+		total.Acquired += s.Acquired
+		total.Canceled += s.Canceled
+		total.Waited += s.Waited
+		total.WaitTime += s.WaitTime
+		if s.MaxWait > total.MaxWait {
+			total.MaxWait = s.MaxWait
+		}
+	}
+	return total
+}
+
+// KeyStats returns the stats of every key used so far.
+func (p *PerKey) KeyStats() map[string]Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make(map[string]Stats, len(p.keys))
+	for key, l := range p.keys {
+		stats[key] = l.Stats()
+	}
+	return stats
+}
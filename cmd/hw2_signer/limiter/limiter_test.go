@@ -0,0 +1,116 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSemaphore(t *testing.T) {
+	s := NewSemaphore(2)
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := s.Acquire(context.Background(), "")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			defer release()
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}()
+	}
+	wg.Wait()
+	if maxRunning != 2 {
+		t.Errorf("expected 2 callers at once, got %d", maxRunning)
+	}
+	if stats := s.Stats(); stats.Acquired != 10 || stats.Waited == 0 || stats.WaitTime < stats.MaxWait || stats.MaxWait == 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestSemaphoreCancel(t *testing.T) {
+	s := NewSemaphore(1)
+	release, _ := s.Acquire(context.Background(), "")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.Acquire(ctx, ""); err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline, got %v", err)
+	}
+	release()
+	if _, err := s.Acquire(context.Background(), ""); err != nil {
+		t.Errorf("expected the released slot, got %v", err)
+	}
+	if stats := s.Stats(); stats.Acquired != 2 || stats.Canceled != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	// a burst of 5 goes right away, the next 5 take 50ms at 100 per second
+	b := NewTokenBucket(100, 5)
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if _, err := b.Acquire(context.Background(), ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if d := time.Since(start); d < 40*time.Millisecond || d > time.Second {
+		t.Errorf("expected about 50ms for 10 tokens, took %s", d)
+	}
+	if stats := b.Stats(); stats.Acquired != 10 || stats.Waited != 5 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// a canceled caller gives its token back
+	slow := NewTokenBucket(1, 1)
+	slow.Acquire(context.Background(), "")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := slow.Acquire(ctx, ""); err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline, got %v", err)
+	}
+	if wait := slow.reserve(time.Now()); wait > time.Second {
+		t.Errorf("expected at most a second to wait, got %s", wait)
+	}
+}
+
+func TestPerKey(t *testing.T) {
+	p := NewPerKey(func() Limiter {
+		return NewSemaphore(1)
+	})
+	releaseA, _ := p.Acquire(context.Background(), "a")
+	// another key is not held up by a
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	releaseB, err := p.Acquire(ctx, "b")
+	if err != nil {
+		t.Errorf("expected b to go on, got %v", err)
+	}
+	if _, err := p.Acquire(ctx, "a"); err != context.DeadlineExceeded {
+		t.Errorf("expected a to wait, got %v", err)
+	}
+	releaseA()
+	releaseB()
+
+	stats := p.KeyStats()
+	if stats["a"].Acquired != 1 || stats["a"].Canceled != 1 || stats["b"].Acquired != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if total := p.Stats(); total.Acquired != 2 || total.Canceled != 1 {
+		t.Errorf("unexpected total %+v", total)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// Policy decides what happens to a value sent while a buffer is full.
+type Policy int
+
+const (
+	// PolicyBlock makes the sender wait for space
+	PolicyBlock Policy = iota
+	// PolicyDropNewest discards the value being sent
+	PolicyDropNewest
+	// PolicyDropOldest discards the value that waited longest
+	PolicyDropOldest
+	// PolicyError fails the pipeline
+	PolicyError
+)
+
+var errBufferFull = errors.New("buffer full")
+
+// LinkOptions configure the buffer between a stage and the next one.
+type LinkOptions struct {
+	// Buffer is how many values wait for the next stage, stageBuffer if 0
+	Buffer int
+	Policy Policy
+}
+
+// LinkStats count what passed through a buffer. A buffer that is often full
+// or whose MaxLen is close to its size sits in front of the slow stage.
+type LinkStats struct {
+	// In counts the values the stage sent and Out those the next stage took
+	In, Out uint64
+	// Dropped counts the values discarded by the policy
+	Dropped uint64
+	// Full counts how often the buffer filled up
+	Full uint64
+	// Len is the number of values waiting now and MaxLen the most there were
+	Len, MaxLen int64
+}
+
+// linkCounters are updated atomically while the pipeline runs.
+type linkCounters struct {
+	in, out, dropped, full uint64
+	len, maxLen            int64
+}
+
+func (c *linkCounters) stats() LinkStats {
+	return LinkStats{
+		In:      atomic.LoadUint64(&c.in),
+		Out:     atomic.LoadUint64(&c.out),
+		Dropped: atomic.LoadUint64(&c.dropped),
+		Full:    atomic.LoadUint64(&c.full),
+		Len:     atomic.LoadInt64(&c.len),
+		MaxLen:  atomic.LoadInt64(&c.maxLen),
+	}
+}
+
+func (c *linkCounters) setLen(n int) {
+	atomic.StoreInt64(&c.len, int64(n))
+	if int64(n) > atomic.LoadInt64(&c.maxLen) {
+		atomic.StoreInt64(&c.maxLen, int64(n))
+	}
+}
+
+// link is a stage that queues up to opts.Buffer values between unbuffered
+// channels, applying opts.Policy when the queue is full.
+func link[T any](opts LinkOptions, c *linkCounters) Stage[T, T] {
+	size := opts.Buffer
+	if size <= 0 {
+		size = stageBuffer
+	}
+	return func(ctx context.Context, in, out chan T) error {
+		var queue []T
+		for in != nil || len(queue) > 0 {
+			src := in
+			if len(queue) >= size && opts.Policy == PolicyBlock {
+				src = nil
+			}
+			var dst chan T
+			var head T
+			if len(queue) > 0 {
+				dst, head = out, queue[0]
+			}
+
+			select {
+			case v, ok := <-src:
+				if !ok {
+					in = nil
+					continue
+				}
+				atomic.AddUint64(&c.in, 1)
+				if len(queue) >= size {
+					atomic.AddUint64(&c.dropped, 1)
+					switch opts.Policy {
+					case PolicyDropNewest:
+						continue
+					case PolicyDropOldest:
+						queue = queue[1:]
+					case PolicyError:
+						return fmt.Errorf("%w after %d values", errBufferFull, atomic.LoadUint64(&c.in)-1)
+					}
+				}
+				queue = append(queue, v)
+				if len(queue) == size {
+					atomic.AddUint64(&c.full, 1)
+				}
+			case dst <- head:
+				queue = queue[1:]
+				atomic.AddUint64(&c.out, 1)
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			c.setLen(len(queue))
+		}
+		return nil
+	}
+}
+
+// Pipeline runs jobs like ExecutePipelineContext with a buffer of its own
+// behind every job, so slow stages can be found and fast ones kept from
+// running too far ahead.
+type Pipeline struct {
+	jobs     []ctxJob
+	counters []*linkCounters
+}
+
+// Add appends j, whose output is buffered as opts say.
+func (p *Pipeline) Add(j ctxJob, opts LinkOptions) *Pipeline {
+	c := &linkCounters{}
+	p.jobs = append(p.jobs, j, link[interface{}](opts, c))
+	p.counters = append(p.counters, c)
+	return p
+}
+
+// Run executes the pipeline. A buffer with PolicyError fails it with an
+// error wrapping errBufferFull.
+func (p *Pipeline) Run(ctx context.Context) error {
+	return runStages(ctx, p.jobs, 0)
+}
+
+// Stats returns the counters of the buffer behind every job, in the order
+// the jobs were added. It can be called while the pipeline runs.
+func (p *Pipeline) Stats() []LinkStats {
+	stats := make([]LinkStats, len(p.counters))
+	for i, c := range p.counters {
+		stats[i] = c.stats()
+	}
+	return stats
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// runLink sends 0..n-1 through a buffer of size 5 to a consumer that takes
+// its time and returns what the consumer got.
+func runLink(policy Policy, n int) ([]int, LinkStats, error) {
+	var got []int
+	p := &Pipeline{}
+	p.Add(withContext(func(in, out chan interface{}) {
+		for i := 0; i < n; i++ {
+			out <- i
+		}
+	}), LinkOptions{Buffer: 5, Policy: policy})
+	p.Add(withContext(func(in, out chan interface{}) {
+		for v := range in {
+			time.Sleep(time.Millisecond)
+			got = append(got, v.(int))
+		}
+	}), LinkOptions{})
+	err := p.Run(context.Background())
+	return got, p.Stats()[0], err
+}
+
+func TestLinkPolicies(t *testing.T) {
+	before := runtime.NumGoroutine()
+	const n = 50
+
+	got, stats, err := runLink(PolicyBlock, n)
+	if err != nil || len(got) != n || got[n-1] != n-1 {
+		t.Errorf("block: expected every value, got %d values, err %v", len(got), err)
+	}
+	if stats.In != n || stats.Out != n || stats.Dropped != 0 || stats.Full == 0 || stats.MaxLen != 5 {
+		t.Errorf("block: unexpected stats %+v", stats)
+	}
+
+	got, stats, err = runLink(PolicyDropNewest, n)
+	if err != nil || len(got) == n || got[0] != 0 {
+		t.Errorf("drop newest: expected the first values only, got %v, err %v", got, err)
+	}
+	if stats.In != n || stats.Out+stats.Dropped != n || stats.Out != uint64(len(got)) {
+		t.Errorf("drop newest: unexpected stats %+v", stats)
+	}
+
+	got, stats, err = runLink(PolicyDropOldest, n)
+	if err != nil || len(got) == n || got[len(got)-1] != n-1 {
+		t.Errorf("drop oldest: expected the last values to survive, got %v, err %v", got, err)
+	}
+	if stats.In != n || stats.Out+stats.Dropped != n {
+		t.Errorf("drop oldest: unexpected stats %+v", stats)
+	}
+
+	_, stats, err = runLink(PolicyError, n)
+	if !errors.Is(err, errBufferFull) {
+		t.Errorf("error: expected errBufferFull, got %v", err)
+	}
+	if stats.Dropped != 1 {
+		t.Errorf("error: unexpected stats %+v", stats)
+	}
+	checkGoroutines(t, before)
+}
+
+func TestLinkCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// a stuck stage shows up as the full buffer in front of it
+	p := &Pipeline{}
+	p.Add(counter, LinkOptions{Buffer: 10})
+	p.Add(func(ctx context.Context, in, out chan interface{}) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, LinkOptions{})
+	err := p.Run(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline, got %v", err)
+	}
+	if stats := p.Stats()[0]; stats.MaxLen != 10 || stats.Out != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	checkGoroutines(t, before)
+}
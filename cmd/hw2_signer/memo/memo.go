@@ -0,0 +1,232 @@
+// Package memo remembers computed strings by key. Concurrent computations
+// of the same key are done once, the least recently used entries are
+// evicted beyond a size bound and the entries can be saved to a file to be
+// reused by the next run.
+package memo
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// errPanicked is returned to those waiting for a computation that panicked,
+// with the value it panicked with.
+var errPanicked = errors.New("memo: computation panicked")
+
+// Stats count the calls to Do.
+type Stats struct {
+	// Hits were answered from the cache and Shared by waiting for the same
+	// key being computed, Misses had to compute it
+	Hits, Shared, Misses uint64
+	// Evictions counts the entries dropped to stay within the bound
+	Evictions uint64
+}
+
+// entry is a cached value, saved to files as it is.
+type entry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// call is a computation in flight. waiters is guarded by Cache.mu.
+type call struct {
+	done    chan struct{}
+	value   string
+	err     error
+	cancel  context.CancelFunc
+	waiters int
+}
+
+// Cache is a memoizing LRU cache safe for concurrent use.
+type Cache struct {
+	max  int
+	path string
+
+	mu      sync.Mutex
+	order   *list.List // of *entry, most recently used first
+	entries map[string]*list.Element
+	calls   map[string]*call
+	stats   Stats
+}
+
+// New returns a Cache holding at most max entries, without a bound if max
+// is 0 or less.
+func New(max int) *Cache {
+	return &Cache{
+		max:     max,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		calls:   make(map[string]*call),
+	}
+}
+
+// Open returns a Cache like New with the entries saved to path, which Save
+// writes again. A missing file is an empty cache.
+func Open(path string, max int) (*Cache, error) {
+	c := New(max)
+	c.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, &fs.PathError{Op: "parse", Path: path, Err: err}
+	}
+	// the file lists the least recently used entries first
+	for _, e := range entries {
+		c.add(e.Key, e.Value)
+	}
+	return c, nil
+}
+
+// Get returns the value of key if it is cached.
+func (c *Cache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*entry).Value, true
+}
+
+// Do returns the value of key, calling compute if it is not cached and not
+// being computed already. Errors are returned to everyone waiting for that
+// computation but not cached. compute runs on a context of its own, which is
+// only canceled once every caller waiting for it has given up, so a caller
+// whose ctx is done does not fail the others.
+func (c *Cache) Do(ctx context.Context, key string, compute func(ctx context.Context) (string, error)) (string, error) {
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		c.stats.Hits++
+		c.mu.Unlock()
+		return el.Value.(*entry).Value, nil
+	}
+	cl, ok := c.calls[key]
+	if ok {
+		c.stats.Shared++
+	} else {
+		cl = &call{done: make(chan struct{})}
+		var callCtx context.Context
+		callCtx, cl.cancel = context.WithCancel(context.Background())
+		c.calls[key] = cl
+		c.stats.Misses++
+		go c.compute(callCtx, key, cl, compute)
+	}
+	cl.waiters++
+	c.mu.Unlock()
+
+	select {
+	case <-cl.done:
+		return cl.value, cl.err
+	case <-ctx.Done():
+	}
+	c.mu.Lock()
+	cl.waiters--
+	if cl.waiters == 0 {
+		// nobody wants the value anymore, the next caller starts afresh
+		cl.cancel()
+		if c.calls[key] == cl {
+			delete(c.calls, key)
+		}
+	}
+	c.mu.Unlock()
+	return "", ctx.Err()
+}
+
+// compute runs the call cl for key and caches its value.
+func (c *Cache) compute(ctx context.Context, key string, cl *call, compute func(ctx context.Context) (string, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			cl.value, cl.err = "", fmt.Errorf("%w: %v", errPanicked, r)
+		}
+		cl.cancel()
+		c.mu.Lock()
+		if c.calls[key] == cl {
+			delete(c.calls, key)
+		}
+		if cl.err == nil {
+			c.add(key, cl.value)
+		}
+		c.mu.Unlock()
+		close(cl.done)
+	}()
+	cl.value, cl.err = compute(ctx)
+}
+
+// add caches value as the most recently used entry, c.mu held.
+func (c *Cache) add(key, value string) {
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*entry).Value = value
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&entry{key, value})
+	for c.max > 0 && c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).Key)
+		c.stats.Evictions++
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Stats returns what the cache counted so far.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Save writes the entries to the file the cache was opened from. It does
+// nothing for a cache made with New. The file is replaced at once, so a
+// failed Save leaves the previous one.
+func (c *Cache) Save() error {
+	if c.path == "" {
+		return nil
+	}
+	c.mu.Lock()
+	entries := make([]entry, 0, c.order.Len())
+	for el := c.order.Back(); el != nil; el = el.Prev() {
+		entries = append(entries, *el.Value.(*entry))
+	}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
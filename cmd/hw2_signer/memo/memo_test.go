@@ -0,0 +1,200 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func value(v string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		return v, nil
+	}
+}
+
+func TestCacheLRU(t *testing.T) {
+	c := New(2)
+	ctx := context.Background()
+	c.Do(ctx, "a", value("1"))
+	c.Do(ctx, "b", value("2"))
+	// a is used again, so b is the one evicted for c
+	if v, _ := c.Do(ctx, "a", value("other")); v != "1" {
+		t.Errorf("expected the cached value, got %v", v)
+	}
+	c.Do(ctx, "c", value("3"))
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("expected b to be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != "1" {
+		t.Errorf("expected a to stay, got %v %v", v, ok)
+	}
+	if stats := c.Stats(); stats.Hits != 1 || stats.Misses != 3 || stats.Evictions != 1 || c.Len() != 2 {
+		t.Errorf("unexpected stats %+v, len %d", stats, c.Len())
+	}
+
+	errFailed := errors.New("failed")
+	c.Do(ctx, "d", func(ctx context.Context) (string, error) {
+		return "", errFailed
+	})
+	if _, ok := c.Get("d"); ok {
+		t.Errorf("expected errors not to be cached")
+	}
+}
+
+func TestCacheSingleflight(t *testing.T) {
+	c := New(0)
+	var calls int32
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := func(ctx context.Context) (string, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return "v", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		if i == 1 {
+			<-started
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.Do(context.Background(), "k", slow); v != "v" || err != nil {
+				t.Errorf("expected v, got %v %v", v, err)
+			}
+		}()
+	}
+
+	// a waiter giving up does not stop the computation
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Do(ctx, "k", slow); err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline, got %v", err)
+	}
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected one computation, got %d", calls)
+	}
+	if stats := c.Stats(); stats.Misses != 1 || stats.Shared != 10 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCachePanic(t *testing.T) {
+	c := New(0)
+	_, err := c.Do(context.Background(), "k", func(ctx context.Context) (string, error) {
+		panic("boom")
+	})
+	if !errors.Is(err, errPanicked) || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected errPanicked with the value, got %v", err)
+	}
+	if v, _ := c.Do(context.Background(), "k", value("v")); v != "v" {
+		t.Errorf("expected a new computation after the panic, got %v", v)
+	}
+}
+
+func TestCacheCancel(t *testing.T) {
+	c := New(0)
+	started, release := make(chan struct{}), make(chan struct{})
+	computeErr := make(chan error, 1)
+	slow := func(ctx context.Context) (string, error) {
+		close(started)
+		select {
+		case <-release:
+			return "v", nil
+		case <-ctx.Done():
+			computeErr <- ctx.Err()
+			return "", ctx.Err()
+		}
+	}
+
+	// the first caller gives up, the one still waiting gets the value
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := c.Do(first, "k", slow)
+		firstErr <- err
+	}()
+	<-started
+	second := make(chan string)
+	go func() {
+		v, _ := c.Do(context.Background(), "k", value("other"))
+		second <- v
+	}()
+	for c.Stats().Shared == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-firstErr; err != context.Canceled {
+		t.Errorf("expected the first caller to be canceled, got %v", err)
+	}
+	close(release)
+	if v := <-second; v != "v" {
+		t.Errorf("expected the shared value, got %q", v)
+	}
+
+	// once everyone has given up the computation is canceled
+	started, release = make(chan struct{}), make(chan struct{})
+	alone, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := c.Do(alone, "other", slow); err != context.Canceled {
+		t.Errorf("expected the caller to be canceled, got %v", err)
+	}
+	for {
+		if v, _ := c.Do(context.Background(), "other", value("fresh")); v == "fresh" {
+			break
+		}
+	}
+	select {
+	case err := <-computeErr:
+		if err != context.Canceled {
+			t.Errorf("expected the computation to be canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("the computation was not canceled")
+	}
+}
+
+func TestCacheSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	c, err := Open(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	for _, k := range []string{"a", "b", "c"} {
+		c.Do(ctx, k, value(k+k))
+	}
+	c.Get("a")
+	if err := c.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// reopened with a smaller bound the least recently used entry goes
+	c, err = Open(path, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := c.Get("b"); ok || c.Len() != 2 {
+		t.Errorf("expected b to be evicted, len %d", c.Len())
+	}
+	if v, ok := c.Get("a"); !ok || v != "aa" {
+		t.Errorf("expected a to be saved, got %v %v", v, ok)
+	}
+
+	if err := New(0).Save(); err != nil {
+		t.Errorf("expected Save without a file to do nothing, got %v", err)
+	}
+}
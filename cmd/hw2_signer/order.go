@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var errSequence = errors.New("sequence broken")
+
+// Seq is a value tagged with the position of the input it came from.
+type Seq[T any] struct {
+	N     uint64
+	Value T
+}
+
+// Sequenced numbers the values it reads, runs stage on them and sends the
+// results in input order. stage has to send exactly one value for each one it
+// reads, keeping its N, in any order. At most window values are inside stage
+// or waiting to be reordered, the input waits for the rest.
+func Sequenced[In, Out any](window int, stage Stage[Seq[In], Seq[Out]]) Stage[In, Out] {
+	if window < 1 {
+		window = 1
+	}
+	return func(ctx context.Context, in chan In, out chan Out) error {
+		tagged, results := make(chan Seq[In]), make(chan Seq[Out])
+		// a value takes a credit when it is tagged and returns it when sent
+		credits := make(chan struct{}, window)
+
+		g := newGroup(ctx)
+		g.run(0, func(ctx context.Context) error {
+			var n uint64
+			for v := range in {
+				if err := send(ctx, credits, struct{}{}); err != nil {
+					return err
+				}
+				if err := send(ctx, tagged, Seq[In]{n, v}); err != nil {
+					return err
+				}
+				n++
+			}
+			return nil
+		}, func() {
+			close(tagged)
+		})
+		g.run(1, func(ctx context.Context) error {
+			return stage(ctx, tagged, results)
+		}, func() {
+			close(results)
+			drain(tagged)
+		})
+		g.run(2, func(ctx context.Context) error {
+			// the reorder buffer never holds more than window values
+			waiting := make(map[uint64]Out, window)
+			var next uint64
+			for r := range results {
+				if _, dup := waiting[r.N]; dup || r.N < next {
+					return fmt.Errorf("%w: %d sent twice", errSequence, r.N)
+				}
+				waiting[r.N] = r.Value
+				for {
+					v, ok := waiting[next]
+					if !ok {
+						break
+					}
+					if err := send(ctx, out, v); err != nil {
+						return err
+					}
+					delete(waiting, next)
+					<-credits
+					next++
+				}
+			}
+			if len(waiting) > 0 {
+				return fmt.Errorf("%w: %d never sent", errSequence, next)
+			}
+			return nil
+		}, func() {})
+		return g.wait()
+	}
+}
+
+// Keep applies f to the value of a Seq, keeping its position.
+func Keep[In, Out any](f func(ctx context.Context, v In) (Out, error)) func(ctx context.Context, s Seq[In]) (Seq[Out], error) {
+	return func(ctx context.Context, s Seq[In]) (Seq[Out], error) {
+		v, err := f(ctx, s.Value)
+		return Seq[Out]{s.N, v}, err
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSequenced(t *testing.T) {
+	var inside, maxInside int32
+	jitter := Apply(Keep(func(ctx context.Context, v int) (int, error) {
+		n := atomic.AddInt32(&inside, 1)
+		for {
+			m := atomic.LoadInt32(&maxInside)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInside, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		atomic.AddInt32(&inside, -1)
+		return v * 10, nil
+	}))
+
+	var inputs, expected []int
+	for i := 0; i < 50; i++ {
+		inputs = append(inputs, i)
+		expected = append(expected, i*10)
+	}
+	got, err := Collect(context.Background(), Sequenced(4, Parallel(8, false, jitter)), inputs...)
+	if err != nil || fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v, err %v", expected, got, err)
+	}
+	if maxInside > 4 {
+		t.Errorf("expected at most 4 values inside the stage, got %d", maxInside)
+	}
+
+	lossy := Stage[Seq[int], Seq[int]](func(ctx context.Context, in, out chan Seq[int]) error {
+		for s := range in {
+			if s.N != 1 {
+				if err := send(ctx, out, s); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if _, err := Collect(context.Background(), Sequenced(4, lossy), 1, 2, 3); !errors.Is(err, errSequence) {
+		t.Errorf("expected errSequence for a lost value, got %v", err)
+	}
+}
+
+func TestSignerOrdered(t *testing.T) {
+	md5, crc32 := DataSignerMd5, DataSignerCrc32
+	defer func() {
+		DataSignerMd5, DataSignerCrc32 = md5, crc32
+	}()
+	// fast hashes finishing in random order, easy to tell apart
+	DataSignerMd5 = func(data string) string {
+		return "md5(" + data + ")"
+	}
+	DataSignerCrc32 = func(data string) string {
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		return "crc(" + data + ")"
+	}
+
+	var inputs []string
+	for i := 0; i < 20; i++ {
+		inputs = append(inputs, strconv.Itoa(i))
+	}
+	got, err := Collect(context.Background(), orderedSingleHash, inputs...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, h := range got {
+		if expected := "crc(" + inputs[i] + ")~crc(md5(" + inputs[i] + "))"; h != expected {
+			t.Errorf("hash %d not match\nGot: %v\nExpected: %v", i, h, expected)
+		}
+	}
+
+	got, err = Collect(context.Background(), orderedSigner, "1", "0")
+	expected := "crc(0crc(1)~crc(md5(1)))crc(1crc(1)~crc(md5(1)))crc(2crc(1)~crc(md5(1)))" +
+		"crc(3crc(1)~crc(md5(1)))crc(4crc(1)~crc(md5(1)))crc(5crc(1)~crc(md5(1)))_" +
+		"crc(0crc(0)~crc(md5(0)))crc(1crc(0)~crc(md5(0)))crc(2crc(0)~crc(md5(0)))" +
+		"crc(3crc(0)~crc(md5(0)))crc(4crc(0)~crc(md5(0)))crc(5crc(0)~crc(md5(0)))"
+	if err != nil || len(got) != 1 || got[0] != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", got, expected)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// ctxJob is a job that stops when ctx is done and can fail the pipeline.
+type ctxJob = Stage[interface{}, interface{}]
+
+// jobPanic is the error a panicking job fails the pipeline with.
+type jobPanic struct {
+	stage int
+	value interface{}
+	stack []byte
+}
+
+func (p *jobPanic) Error() string {
+	return fmt.Sprintf("job %d panicked: %v\n%s", p.stage, p.value, p.stack)
+}
+
+// withContext runs a plain job as a ctxJob. It cannot see the context, but
+// the pipeline still drains its input so it never blocks on a stopped stage.
+func withContext(j job) ctxJob {
+	return func(ctx context.Context, in, out chan interface{}) error {
+		j(in, out)
+		return nil
+	}
+}
+
+// send hands v to the next stage unless ctx is done first.
+func send[T any](ctx context.Context, out chan T, v T) error {
+	select {
+	case out <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drain discards whatever is still sent on ch once its reader has returned,
+// so the sender never blocks. A nil channel has no sender.
+func drain[T any](ch chan T) {
+	if ch == nil {
+		return
+	}
+	for range ch {
+	}
+}
+
+// group runs stages until all of them have returned. The first error or
+// panic cancels the context of the others and is returned by wait.
+type group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+	err    error
+}
+
+func newGroup(ctx context.Context) *group {
+	ctx, cancel := context.WithCancel(ctx)
+	return &group{ctx: ctx, cancel: cancel}
+}
+
+func (g *group) fail(err error) {
+	g.once.Do(func() {
+		g.err = err
+		g.cancel()
+	})
+}
+
+// run starts f as the given stage. done runs after the outcome of f has been
+// recorded, so closing and draining channels there cannot wait on a stage
+// that is only stopped by that outcome.
+func (g *group) run(stage int, f func(ctx context.Context) error, done func()) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer done()
+		defer func() {
+			if r := recover(); r != nil {
+				g.fail(&jobPanic{stage: stage, value: r, stack: debug.Stack()})
+			}
+		}()
+		if err := f(g.ctx); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+func (g *group) wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
+
+// ExecutePipelineContext runs jobs connected like ExecutePipeline and returns
+// the first error one of them returns or panics with. That error cancels the
+// context of all other jobs. Once a job has returned, whatever is still sent
+// to it is drained, so the stages before it never block and every goroutine
+// has finished when ExecutePipelineContext returns.
+func ExecutePipelineContext(ctx context.Context, jobs ...ctxJob) error {
+	return runStages(ctx, jobs, stageBuffer)
+}
+
+// runStages connects jobs with channels of the given capacity. The output of
+// the last job is discarded.
+func runStages(ctx context.Context, jobs []ctxJob, buffer int) error {
+	g := newGroup(ctx)
+	var input, output chan interface{}
+	for i, jb := range jobs {
+		in, out, j := input, make(chan interface{}, buffer), jb
+		output = out
+		g.run(i, func(ctx context.Context) error {
+			return j(ctx, in, out)
+		}, func() {
+			close(out)
+			drain(in)
+		})
+
+		// the output of this job is the input of the next one
+		input = output
+	}
+	g.run(len(jobs), func(ctx context.Context) error {
+		return nil
+	}, func() {
+		drain(input)
+	})
+	return g.wait()
+}
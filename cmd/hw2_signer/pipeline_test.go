@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// counter emits 0, 1, 2, ... until the pipeline is stopped.
+func counter(ctx context.Context, in, out chan interface{}) error {
+	for i := 0; ; i++ {
+		if err := send[interface{}](ctx, out, i); err != nil {
+			return err
+		}
+	}
+}
+
+func checkGoroutines(t *testing.T, before int) {
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Errorf("goroutines leaked: %d before, %d after", before, runtime.NumGoroutine())
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPipelineError(t *testing.T) {
+	before := runtime.NumGoroutine()
+	errTooBig := errors.New("too big")
+	var last interface{}
+	err := ExecutePipelineContext(context.Background(),
+		counter,
+		func(ctx context.Context, in, out chan interface{}) error {
+			for v := range in {
+				if v.(int) > 10 {
+					return errTooBig
+				}
+				if err := send(ctx, out, v); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		withContext(func(in, out chan interface{}) {
+			for v := range in {
+				last = v
+			}
+		}),
+	)
+	if err != errTooBig {
+		t.Errorf("expected the job error, got %v", err)
+	}
+	if last != 10 {
+		t.Errorf("expected the values before the error to pass, last was %v", last)
+	}
+	checkGoroutines(t, before)
+}
+
+func TestPipelinePanic(t *testing.T) {
+	before := runtime.NumGoroutine()
+	err := ExecutePipelineContext(context.Background(),
+		counter,
+		withContext(func(in, out chan interface{}) {
+			<-in
+			panic("boom")
+		}),
+	)
+	var p *jobPanic
+	if !errors.As(err, &p) || p.stage != 1 || p.value != "boom" {
+		t.Errorf("expected a panic of job 1, got %v", err)
+	}
+	checkGoroutines(t, before)
+
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("expected ExecutePipeline to panic with the job's value, got %v", r)
+		}
+	}()
+	ExecutePipeline(func(in, out chan interface{}) {
+		panic("boom")
+	})
+}
+
+func TestPipelineCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := ExecutePipelineContext(ctx,
+		counter,
+		func(ctx context.Context, in, out chan interface{}) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline to stop the pipeline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("pipeline took %s to stop", elapsed)
+	}
+	checkGoroutines(t, before)
+}
+
+func TestPipelineDrain(t *testing.T) {
+	// the last job stops reading long before the first stops sending, far
+	// more than the channels between them hold
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ExecutePipeline(
+			func(in, out chan interface{}) {
+				for i := 0; i < 1000; i++ {
+					out <- i
+				}
+			},
+			func(in, out chan interface{}) {
+				<-in
+			},
+		)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("pipeline blocked on a job that stopped reading")
+	}
+}
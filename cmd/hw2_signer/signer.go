@@ -1,118 +1,119 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"sort"
-	"strconv"
 	"strings"
-	"sync"
+
+	"go-webservices/cmd/hw2_signer/memo"
 )
 
 func ExecutePipeline(jobs ...job) {
-	var input, output chan interface{}
-	var wg sync.WaitGroup
-	wg.Add(len(jobs))
-
-	for _, jb := range jobs {
-		output = make(chan interface{}, 100)
-
-		go func(in, out chan interface{}, j job, w *sync.WaitGroup) {
-			defer w.Done()
-			defer close(out)
-			j(in, out)
-		}(input, output, jb, &wg)
-
-		// If function signature would allow returning output channel, we could assign return value here,
-		// but since we work with function parameters only, we switch channels here, giving next job in line
-		// closed channel and creating new output channel at the start of the cycle
-		input = output
+	ctxJobs := make([]ctxJob, len(jobs))
+	for i, jb := range jobs {
+		ctxJobs[i] = withContext(jb)
+	}
+	// plain jobs can only fail by panicking, which is passed on to the caller
+	if err := ExecutePipelineContext(context.Background(), ctxJobs...); err != nil {
+		panic(err.(*jobPanic).value)
 	}
+}
 
-	wg.Wait()
+// hashWorkers bounds how many values each hash stage works on at once, and
+// in order how many results wait for an earlier one.
+const hashWorkers = MaxInputDataLen
+
+// hashStage signs up to hashWorkers values at once with f. With inOrder set
+// the results are sent in input order, otherwise as they complete.
+func hashStage(f ContextSigner, inOrder bool) Stage[string, string] {
+	hash := f.SignContext
+	if !inOrder {
+		return Parallel(hashWorkers, false, Apply(hash))
+	}
+	return Sequenced(hashWorkers, Parallel(hashWorkers, false, Apply(Keep(hash))))
 }
 
-func SingleHash(in, out chan interface{}) {
-	var wg sync.WaitGroup
-	throttle := make(chan struct{}, 1)
+var (
+	singleHash        = hashStage(mustParseFormula(DefaultSingleHash), false)
+	multiHash         = hashStage(mustParseFormula(DefaultMultiHash), false)
+	orderedSingleHash = hashStage(mustParseFormula(DefaultSingleHash), true)
+	orderedMultiHash  = hashStage(mustParseFormula(DefaultMultiHash), true)
+)
+
+// combineResults joins all values sorted with "_" once the input is closed.
+func combineResults(ctx context.Context, in, out chan string) error {
+	var results []string
 
 	for data := range in {
-		data := fmt.Sprintf("%v", data)
-		wg.Add(1)
-
-		go func(dt string, o chan interface{}, th chan struct{}, w *sync.WaitGroup) {
-			defer w.Done()
-			var o1, o2 chan string
-
-			o1 = make(chan string, 1)
-			go func(d string) {
-				crc32 := DataSignerCrc32(d)
-				o1 <- crc32
-				close(o1)
-			}(dt)
-
-			o2 = make(chan string, 1)
-			go func(d string, t chan struct{}) {
-				t <- struct{}{}
-				md5 := DataSignerMd5(d)
-				<-t
-				o2 <- md5
-			}(dt, th)
-			go func() {
-				md5 := <-o2
-				crcmd := DataSignerCrc32(md5)
-				o2 <- crcmd
-				close(o2)
-			}()
-
-			result := <-o1 + "~" + <-o2
-			out <- result
-		}(data, out, throttle, &wg)
+		results = append(results, data)
 	}
+	sort.Strings(results)
+	result := strings.Join(results, "_")
 
-	wg.Wait()
-	close(throttle)
+	return send(ctx, out, result)
 }
 
-func MultiHash(in, out chan interface{}) {
-	var wg sync.WaitGroup
-	for d := range in {
-		data := fmt.Sprintf("%v", d)
-		wg.Add(1)
-
-		go func(dt string, ot chan interface{}, w *sync.WaitGroup) {
-			defer w.Done()
-			var results [6]chan string
-			for i := 0; i < 6; i++ {
-				results[i] = make(chan string, 1)
-				go func(o chan string, th int, d string) {
-					val := DataSignerCrc32(strconv.Itoa(th) + d)
-					o <- val
-				}(results[i], i, dt)
-
-			}
-
-			var result string
-			for _, c := range results {
-				result += <-c
-				close(c)
-			}
-			ot <- result
-		}(data, out, &wg)
+// joinResults joins all values with "_" in the order they arrive.
+func joinResults(ctx context.Context, in, out chan string) error {
+	var results []string
+	for data := range in {
+		results = append(results, data)
 	}
-	wg.Wait()
+	return send(ctx, out, strings.Join(results, "_"))
+}
+
+var (
+	// signer is the whole hash chain as one typed stage.
+	signer = Then(Then(singleHash, multiHash), combineResults)
+	// orderedSigner joins the hashes in input order instead of sorting them.
+	orderedSigner = Then(Then(orderedSingleHash, orderedMultiHash), joinResults)
+)
+
+func SingleHash(in, out chan interface{}) {
+	ToJob(Then(stringify, singleHash))(in, out)
+}
+
+func MultiHash(in, out chan interface{}) {
+	ToJob(Then(stringify, multiHash))(in, out)
 }
 
 func CombineResults(in, out chan interface{}) {
-	var results []string
+	ToJob(Then(stringify, combineResults))(in, out)
+}
 
-	for d := range in {
-		data := fmt.Sprintf("%v", d)
-		results = append(results, data)
+// SingleHashOrdered is SingleHash sending the hashes in input order.
+func SingleHashOrdered(in, out chan interface{}) {
+	ToJob(Then(stringify, orderedSingleHash))(in, out)
+}
+
+// MultiHashOrdered is MultiHash sending the hashes in input order.
+func MultiHashOrdered(in, out chan interface{}) {
+	ToJob(Then(stringify, orderedMultiHash))(in, out)
+}
+
+// JoinResults is CombineResults for ordered hashes, keeping their order.
+func JoinResults(in, out chan interface{}) {
+	ToJob(Then(stringify, joinResults))(in, out)
+}
+
+// HashJob is a job computing the formula spec for every value, the way
+// SingleHash and MultiHash compute DefaultSingleHash and DefaultMultiHash.
+func HashJob(spec string, inOrder bool) (job, error) {
+	f, err := ParseFormula(spec)
+	if err != nil {
+		return nil, err
 	}
-	sort.Strings(results)
-	result := strings.Join(results, "_")
+	return ToJob(Then(stringify, hashStage(f, inOrder))), nil
+}
 
-	out <- result
+// CachedHashJob is HashJob remembering the hashes in c, so values hashed
+// before, or being hashed right now, are not hashed again.
+func CachedHashJob(spec string, inOrder bool, c *memo.Cache) (job, error) {
+	f, err := ParseFormula(spec)
+	if err != nil {
+		return nil, err
+	}
+	return ToJob(Then(stringify, hashStage(Cached(f, c, spec), inOrder))), nil
 }
 
 func main() {
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"sync"
+
+	"go-webservices/cmd/hw2_signer/limiter"
+	"go-webservices/cmd/hw2_signer/memo"
+)
+
+// Signer hashes a value into a printable string.
+type Signer interface {
+	Sign(data string) string
+}
+
+// SignerFunc makes a plain function a Signer.
+type SignerFunc func(data string) string
+
+func (f SignerFunc) Sign(data string) string {
+	return f(data)
+}
+
+// ContextSigner is a Signer that may wait before signing, giving up when
+// ctx is done. Formulas use SignContext when a signer has it.
+type ContextSigner interface {
+	Signer
+	SignContext(ctx context.Context, data string) (string, error)
+}
+
+// LimitedSigner signs within the limits of a limiter, under Key for
+// limiters that count per key.
+type LimitedSigner struct {
+	Signer
+	Limiter limiter.Limiter
+	Key     string
+}
+
+// Limited wraps s so that it only signs within l.
+func Limited(s Signer, l limiter.Limiter) *LimitedSigner {
+	return &LimitedSigner{Signer: s, Limiter: l}
+}
+
+func (l *LimitedSigner) SignContext(ctx context.Context, data string) (string, error) {
+	release, err := l.Limiter.Acquire(ctx, l.Key)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return l.Signer.Sign(data), nil
+}
+
+func (l *LimitedSigner) Sign(data string) string {
+	h, _ := l.SignContext(context.Background(), data)
+	return h
+}
+
+// CachedSigner remembers in Cache what Signer returned, by Prefix, the data
+// and DataSignerSalt, so the same data is signed once. Prefix tells apart the
+// signers sharing a cache, the salt keeps hashes saved under another salt
+// from being reused.
+type CachedSigner struct {
+	Signer
+	Cache  *memo.Cache
+	Prefix string
+}
+
+// Cached wraps s to remember its hashes in c under prefix.
+func Cached(s Signer, c *memo.Cache, prefix string) *CachedSigner {
+	return &CachedSigner{Signer: s, Cache: c, Prefix: prefix}
+}
+
+func (c *CachedSigner) SignContext(ctx context.Context, data string) (string, error) {
+	return c.Cache.Do(ctx, c.Prefix+"\x00"+DataSignerSalt+"\x00"+data, func(ctx context.Context) (string, error) {
+		if s, ok := c.Signer.(ContextSigner); ok {
+			return s.SignContext(ctx, data)
+		}
+		return c.Signer.Sign(data), nil
+	})
+}
+
+func (c *CachedSigner) Sign(data string) string {
+	h, _ := c.SignContext(context.Background(), data)
+	return h
+}
+
+// hashSigner signs with the hex digest of a hash.Hash, salted like the
+// DataSigner functions.
+func hashSigner(newHash func() hash.Hash) Signer {
+	return SignerFunc(func(data string) string {
+		h := newHash()
+		h.Write([]byte(data + DataSignerSalt))
+		return hex.EncodeToString(h.Sum(nil))
+	})
+}
+
+// NewHMAC signs with the HMAC of newHash keyed with key.
+func NewHMAC(newHash func() hash.Hash, key string) Signer {
+	return hashSigner(func() hash.Hash {
+		return hmac.New(newHash, []byte(key))
+	})
+}
+
+var (
+	signersMu sync.RWMutex
+	signers   = map[string]Signer{
+		// DataSignerMd5 and DataSignerCrc32 are looked up on every call,
+		// tests replace them
+		// md5 overheats when called concurrently
+		"md5": Limited(SignerFunc(func(data string) string {
+			return DataSignerMd5(data)
+		}), limiter.NewSemaphore(1)),
+		"crc32": SignerFunc(func(data string) string {
+			return DataSignerCrc32(data)
+		}),
+		"sha1":   hashSigner(sha1.New),
+		"sha256": hashSigner(sha256.New),
+		"xxhash": SignerFunc(func(data string) string {
+			return fmt.Sprintf("%016x", xxhash64([]byte(data+DataSignerSalt), 0))
+		}),
+	}
+)
+
+// RegisterSigner makes s available to formulas as name, for example an
+// HMAC made with NewHMAC. It panics if the name is taken, like
+// sql.Register does.
+func RegisterSigner(name string, s Signer) {
+	signersMu.Lock()
+	defer signersMu.Unlock()
+	if !isIdent(name) {
+		panic(fmt.Sprintf("signer name %q is not an identifier", name))
+	}
+	if _, dup := signers[name]; dup {
+		panic(fmt.Sprintf("signer %q registered twice", name))
+	}
+	signers[name] = s
+}
+
+func lookupSigner(name string) (Signer, bool) {
+	signersMu.RLock()
+	defer signersMu.RUnlock()
+	s, ok := signers[name]
+	return s, ok
+}
+
+// LimiterStats returns the stats of the limiters of the registered
+// signers, by signer name.
+func LimiterStats() map[string]limiter.Stats {
+	signersMu.RLock()
+	defer signersMu.RUnlock()
+	stats := make(map[string]limiter.Stats)
+	for name, s := range signers {
+		if l, ok := s.(*LimitedSigner); ok {
+			stats[name] = l.Limiter.Stats()
+		}
+	}
+	return stats
+}
+
+// Signers lists the registered names, sorted.
+func Signers() []string {
+	signersMu.RLock()
+	defer signersMu.RUnlock()
+	names := make([]string, 0, len(signers))
+	for name := range signers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSigners(t *testing.T) {
+	cases := []struct {
+		signer Signer
+		data   string
+		hash   string
+	}{
+		{signers["sha1"], "abc", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{signers["sha256"], "abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{signers["xxhash"], "", "ef46db3751d8e999"},
+		{signers["xxhash"], "a", "d24ec4f1a98c6e5b"},
+		{signers["xxhash"], "abc", "44bc2cf5ad770999"},
+		{signers["xxhash"], "Nobody inspects the spammish repetition", "fbcea83c8a378bf1"},
+		{NewHMAC(sha256.New, "key"), "The quick brown fox jumps over the lazy dog", "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+	}
+	for _, c := range cases {
+		if got := c.signer.Sign(c.data); got != c.hash {
+			t.Errorf("hash of %q not match\nGot: %v\nExpected: %v", c.data, got, c.hash)
+		}
+	}
+}
+
+func TestRegisterSigner(t *testing.T) {
+	RegisterSigner("test-upper", SignerFunc(strings.ToUpper))
+	defer func() {
+		signersMu.Lock()
+		delete(signers, "test-upper")
+		signersMu.Unlock()
+	}()
+
+	f, err := ParseFormula("test-upper(x)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := f.Sign("abc"); got != "ABC" {
+		t.Errorf("expected ABC, got %v", got)
+	}
+
+	for _, name := range []string{"test-upper", "x(", ""} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic registering %q", name)
+				}
+			}()
+			RegisterSigner(name, SignerFunc(strings.ToUpper))
+		}()
+	}
+}
+
+func TestMd5NeverConcurrent(t *testing.T) {
+	md5, crc32 := DataSignerMd5, DataSignerCrc32
+	defer func() {
+		DataSignerMd5, DataSignerCrc32 = md5, crc32
+	}()
+	var running, calls int32
+	DataSignerMd5 = func(data string) string {
+		if atomic.AddInt32(&running, 1) > 1 {
+			t.Errorf("md5 called concurrently")
+		}
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return "md5(" + data + ")"
+	}
+	DataSignerCrc32 = func(data string) string {
+		return "crc(" + data + ")"
+	}
+
+	var inputs []string
+	for i := 0; i < 30; i++ {
+		inputs = append(inputs, strconv.Itoa(i))
+	}
+	before := LimiterStats()["md5"]
+
+	// the stages and a formula calling md5 several times all share it
+	nested := mustParseFormula("md5(x)md5(md5(x))md5(x)")
+	var wg sync.WaitGroup
+	for _, stage := range []Stage[string, string]{singleHash, orderedSingleHash, hashStage(nested, false)} {
+		wg.Add(1)
+		go func(stage Stage[string, string]) {
+			defer wg.Done()
+			if _, err := Collect(context.Background(), stage, inputs...); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(stage)
+	}
+	wg.Wait()
+
+	if calls != 30*6 {
+		t.Errorf("expected %d md5 calls, got %d", 30*6, calls)
+	}
+	stats := LimiterStats()["md5"]
+	if stats.Acquired-before.Acquired != 30*6 || stats.Waited == before.Waited || stats.WaitTime == 0 {
+		t.Errorf("unexpected md5 limiter stats %+v", stats)
+	}
+}
+
+func TestLimitedCancel(t *testing.T) {
+	md5 := signers["md5"].(*LimitedSigner)
+	release, _ := md5.Limiter.Acquire(context.Background(), "")
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := mustParseFormula("crc32(md5(x))").SignContext(ctx, "abc"); err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline while md5 is busy, got %v", err)
+	}
+}
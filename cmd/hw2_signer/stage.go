@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// stageBuffer is the capacity of the channels between stages.
+const stageBuffer = 100
+
+// Stage reads values of type In until in is closed and sends values of type
+// Out. Whoever runs a stage closes out once it has returned.
+type Stage[In, Out any] func(ctx context.Context, in chan In, out chan Out) error
+
+// Then connects the output of first to the input of second, with both
+// running concurrently. The stage types have to match at compile time.
+func Then[A, B, C any](first Stage[A, B], second Stage[B, C]) Stage[A, C] {
+	return func(ctx context.Context, in chan A, out chan C) error {
+		mid := make(chan B, stageBuffer)
+		g := newGroup(ctx)
+		g.run(0, func(ctx context.Context) error {
+			return first(ctx, in, mid)
+		}, func() {
+			close(mid)
+		})
+		g.run(1, func(ctx context.Context) error {
+			return second(ctx, mid, out)
+		}, func() {
+			drain(mid)
+		})
+		return g.wait()
+	}
+}
+
+// Map is a stage applying f to every value.
+func Map[In, Out any](f func(In) Out) Stage[In, Out] {
+	return func(ctx context.Context, in chan In, out chan Out) error {
+		for v := range in {
+			if err := send(ctx, out, f(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// stringify turns the values of a plain job into the strings the signer
+// stages work on.
+var stringify = Map(func(v interface{}) string {
+	return fmt.Sprintf("%v", v)
+})
+
+// Collect runs stage over inputs and returns everything it sent, in order.
+func Collect[In, Out any](ctx context.Context, stage Stage[In, Out], inputs ...In) ([]Out, error) {
+	in, out := make(chan In, stageBuffer), make(chan Out, stageBuffer)
+	var results []Out
+	g := newGroup(ctx)
+	g.run(0, func(ctx context.Context) error {
+		for _, v := range inputs {
+			if err := send(ctx, in, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func() {
+		close(in)
+	})
+	g.run(1, func(ctx context.Context) error {
+		return stage(ctx, in, out)
+	}, func() {
+		close(out)
+		drain(in)
+	})
+	g.run(2, func(ctx context.Context) error {
+		for v := range out {
+			results = append(results, v)
+		}
+		return nil
+	}, func() {})
+	return results, g.wait()
+}
+
+// ToJob runs a typed stage as a plain job. Values that are not of type In
+// make it panic, like a failing stage does, since a job cannot return errors.
+func ToJob[In, Out any](stage Stage[In, Out]) job {
+	return func(in, out chan interface{}) {
+		typedIn, typedOut := make(chan In, stageBuffer), make(chan Out, stageBuffer)
+		g := newGroup(context.Background())
+		g.run(0, func(ctx context.Context) error {
+			for v := range in {
+				if err := send(ctx, typedIn, v.(In)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, func() {
+			close(typedIn)
+		})
+		g.run(1, func(ctx context.Context) error {
+			return stage(ctx, typedIn, typedOut)
+		}, func() {
+			close(typedOut)
+			drain(typedIn)
+		})
+		g.run(2, func(ctx context.Context) error {
+			for v := range typedOut {
+				out <- v
+			}
+			return nil
+		}, func() {})
+		if err := g.wait(); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStageThen(t *testing.T) {
+	double := Map(func(v int) int { return v * 2 })
+	format := Map(func(v int) string { return "#" + strconv.Itoa(v) })
+	got, err := Collect(context.Background(), Then(double, format), 1, 2, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(got, " ") != "#2 #4 #6" {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", got, "#2 #4 #6")
+	}
+
+	errOdd := errors.New("odd")
+	failing := Stage[int, int](func(ctx context.Context, in, out chan int) error {
+		for v := range in {
+			if v%2 == 1 {
+				return errOdd
+			}
+			if err := send(ctx, out, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if _, err := Collect(context.Background(), Then(double, Then(failing, format)), 1, 2, 3); err != nil {
+		t.Errorf("unexpected error for even values: %v", err)
+	}
+	if _, err := Collect(context.Background(), Then(failing, format), 2, 3, 4); err != errOdd {
+		t.Errorf("expected the stage error, got %v", err)
+	}
+}
+
+func TestStageSigner(t *testing.T) {
+	expected := "1173136728138862632818075107442090076184424490584241521304_1696913515191343735512658979631549563179965036907783101867_27225454331033649287118297354036464389062965355426795162684_29568666068035183841425683795340791879727309630931025356555_3994492081516972096677631278379039212655368881548151736_4958044192186797981418233587017209679042592862002427381542_4958044192186797981418233587017209679042592862002427381542"
+
+	start := time.Now()
+	got, err := Collect(context.Background(), Then(Map(strconv.Itoa), signer), 0, 1, 1, 2, 3, 5, 8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0] != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", got, expected)
+	}
+	if end := time.Since(start); end > 3*time.Second {
+		t.Errorf("execition too long\nGot: %s\nExpected: <%s", end, 3*time.Second)
+	}
+}
+
+func TestToJobWrongType(t *testing.T) {
+	err := ExecutePipelineContext(context.Background(),
+		withContext(func(in, out chan interface{}) {
+			out <- "not a number"
+		}),
+		withContext(ToJob(Map(func(v int) int { return v }))),
+	)
+	var p *jobPanic
+	if !errors.As(err, &p) {
+		t.Errorf("expected a panic for the wrong type, got %v", err)
+	}
+}
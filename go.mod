@@ -1,3 +1,7 @@
 module go-webservices
 
-go 1.17
+go 1.18
+
+require github.com/mailru/easyjson v0.9.2
+
+require github.com/josharian/intern v1.0.0 // indirect